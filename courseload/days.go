@@ -0,0 +1,83 @@
+package courseload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrInvalidWeekday = errors.New("invalid weekday")
+
+// A set of days of the week, one bit per time.Weekday
+type Weekdays uint8
+
+// Banner day letters in the order they are written. "R" is Thursday and
+// "U" is Sunday so that no two days share a letter
+var weekdayLetters = []struct {
+	letter  rune
+	weekday time.Weekday
+}{
+	{'M', time.Monday},
+	{'T', time.Tuesday},
+	{'W', time.Wednesday},
+	{'R', time.Thursday},
+	{'F', time.Friday},
+	{'S', time.Saturday},
+	{'U', time.Sunday},
+}
+
+// Parses a days string such as "MWF" or "TR". Letters are case insensitive
+// and whitespace is ignored, so an empty string is the empty set
+func ParseWeekdays(s string) (Weekdays, error) {
+	var days Weekdays
+
+outer:
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		for _, l := range weekdayLetters {
+			if unicode.ToUpper(r) == l.letter {
+				days |= 1 << l.weekday
+				continue outer
+			}
+		}
+
+		return 0, fmt.Errorf("%w: %q in %q", ErrInvalidWeekday, r, s)
+	}
+
+	return days, nil
+}
+
+func (d Weekdays) Has(day time.Weekday) bool {
+	return d&(1<<day) != 0
+}
+
+// The days in the set, Monday first
+func (d Weekdays) Days() []time.Weekday {
+	days := make([]time.Weekday, 0, 7)
+
+	for _, l := range weekdayLetters {
+		if d.Has(l.weekday) {
+			days = append(days, l.weekday)
+		}
+	}
+
+	return days
+}
+
+// The set as Banner writes it, such as "MWF"
+func (d Weekdays) String() string {
+	var b strings.Builder
+
+	for _, l := range weekdayLetters {
+		if d.Has(l.weekday) {
+			b.WriteRune(l.letter)
+		}
+	}
+
+	return b.String()
+}
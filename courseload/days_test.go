@@ -0,0 +1,46 @@
+package courseload
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		in     string
+		days   []time.Weekday
+		string string
+		err    error
+	}{
+		{"MWF", []time.Weekday{time.Monday, time.Wednesday, time.Friday}, "MWF", nil},
+		{"TR", []time.Weekday{time.Tuesday, time.Thursday}, "TR", nil},
+		{"r t", []time.Weekday{time.Tuesday, time.Thursday}, "TR", nil},
+		{"SU", []time.Weekday{time.Saturday, time.Sunday}, "SU", nil},
+		{"MM", []time.Weekday{time.Monday}, "M", nil},
+		{"", []time.Weekday{}, "", nil},
+		{"TBA", nil, "", ErrInvalidWeekday},
+		{"M-W", nil, "", ErrInvalidWeekday},
+	}
+
+	for _, test := range tests {
+		days, err := ParseWeekdays(test.in)
+		if !errors.Is(err, test.err) {
+			t.Errorf("ParseWeekdays(%q) error = %v, want %v", test.in, err, test.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if got := days.Days(); !slices.Equal(got, test.days) {
+			t.Errorf("ParseWeekdays(%q).Days() = %v, want %v", test.in, got, test.days)
+		}
+
+		if got := days.String(); got != test.string {
+			t.Errorf("ParseWeekdays(%q).String() = %q, want %q", test.in, got, test.string)
+		}
+	}
+}
@@ -1,14 +1,25 @@
 package courseload
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Instructor struct {
 	LastName  string `json:"LAST_NAME"`
 	FirstName string `json:"FIRST_NAME"`
 	Email     string `json:"EMAIL"`
+
+	// Not in the UNH feed, so empty for scraped instructors. Only set by
+	// course dumps loaded with database.ImportCoursesJSON
+	Office      string `json:"OFFICE"`
+	OfficeHours string `json:"OFFICE_HOURS"`
 }
 
 type Meeting struct {
+	// The meeting's row in the database, 0 for meetings not loaded from it
+	ID int `json:"ID,omitempty"`
+
 	Days     string `json:"DAYS"`
 	Building string `json:"BUILDING"`
 	Room     string `json:"ROOM"`
@@ -23,11 +34,27 @@ type CourseData struct {
 	Instructors []Instructor `json:"INSTRUCTORS"`
 	Meetings    []Meeting    `json:"MEETINGS"`
 	SectionNum  string       `json:"SYVSCHD_SEQ_NUMB"`
+
+	// Not in the UNH feed, so scraped courses leave these 0 and a
+	// SeatsTotal of 0 means the seats aren't known. They are only set by
+	// course dumps loaded with database.ImportCoursesJSON
+	SeatsTotal     int `json:"SEATS_TOTAL"`
+	SeatsAvailable int `json:"SEATS_AVAILABLE"`
+	WaitlistCount  int `json:"WAITLIST_COUNT"`
+
+	// Also missing from the UNH feed, so 0 for scraped courses, meaning
+	// the credits aren't known
+	Credits float64 `json:"CREDITS"`
 }
 
 type Course struct {
 	CRN  string     `json:"TERM_CRN"`
 	Data CourseData `json:"COURSE_DATA"`
+
+	// When the course was first stored and last changed, only set on
+	// courses loaded from the database
+	CreatedAt *time.Time `json:"CREATED_AT,omitempty"`
+	UpdatedAt *time.Time `json:"UPDATED_AT,omitempty"`
 }
 
 func (c *Course) JSON() []byte {
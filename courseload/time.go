@@ -0,0 +1,81 @@
+package courseload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMeetingTime = errors.New("invalid meeting time")
+
+// Returned for meetings Banner lists as "TBA", which have no set time
+var ErrMeetingTimeTBA = errors.New("meeting time is TBA")
+
+// Accepted clock layouts, tried in order once the input is lowercased and
+// stripped of spaces and periods ("10:00 A.M." becomes "10:00am")
+var clockLayouts = []string{"3:04pm", "3pm", "15:04"}
+
+// Parses a meeting time such as "10:00 am - 11:15 am" into start and end
+// times on the zero date. When only the end carries am/pm, as in
+// "11:00 - 12:15 pm", the start takes whichever meridiem keeps it before
+// the end. TBA meetings return ErrMeetingTimeTBA
+func ParseMeetingTime(s string) (start, end time.Time, err error) {
+	trimmed := strings.TrimSpace(s)
+
+	if strings.EqualFold(trimmed, "tba") {
+		return start, end, ErrMeetingTimeTBA
+	}
+
+	parts := strings.Split(strings.ReplaceAll(trimmed, "–", "-"), "-")
+
+	if len(parts) != 2 {
+		return start, end, fmt.Errorf("%w: %q", ErrInvalidMeetingTime, s)
+	}
+
+	startClock, endClock := normalizeClock(parts[0]), normalizeClock(parts[1])
+
+	if end, err = parseClock(endClock); err != nil {
+		return start, end, fmt.Errorf("%w: %q", ErrInvalidMeetingTime, s)
+	}
+
+	if !hasMeridiem(startClock) && hasMeridiem(endClock) {
+		startClock += endClock[len(endClock)-2:]
+
+		// "11:00 - 12:15 pm" starts in the morning
+		if start, err = parseClock(startClock); err == nil && start.After(end) {
+			startClock = startClock[:len(startClock)-2] + "am"
+		}
+	}
+
+	if start, err = parseClock(startClock); err != nil {
+		return start, end, fmt.Errorf("%w: %q", ErrInvalidMeetingTime, s)
+	}
+
+	if !end.After(start) {
+		return start, end, fmt.Errorf("%w: %q ends before it starts", ErrInvalidMeetingTime, s)
+	}
+
+	return start, end, nil
+}
+
+func normalizeClock(s string) string {
+	return strings.NewReplacer(" ", "", ".", "").Replace(strings.ToLower(s))
+}
+
+func hasMeridiem(clock string) bool {
+	return strings.HasSuffix(clock, "am") || strings.HasSuffix(clock, "pm")
+}
+
+func parseClock(clock string) (time.Time, error) {
+	var err error
+
+	for _, layout := range clockLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, clock); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
@@ -0,0 +1,53 @@
+package courseload
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMeetingTime(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end string
+		err        error
+	}{
+		{"10:00 am - 11:15 am", "10:00", "11:15", nil},
+		{"9:10am-10:00am", "09:10", "10:00", nil},
+		{"11:10am-12:30pm", "11:10", "12:30", nil},
+		{"11:00 - 12:15 pm", "11:00", "12:15", nil},
+		{"1:00 - 2:15 pm", "13:00", "14:15", nil},
+		{"12:40pm-2:00pm", "12:40", "14:00", nil},
+		{"6:30 p.m. – 9:20 p.m.", "18:30", "21:20", nil},
+		{"TBA", "", "", ErrMeetingTimeTBA},
+		{" tba ", "", "", ErrMeetingTimeTBA},
+		{"", "", "", ErrInvalidMeetingTime},
+		{"10:00am", "", "", ErrInvalidMeetingTime},
+		{"2:00pm-1:00pm", "", "", ErrInvalidMeetingTime},
+		{"noon-1:00pm", "", "", ErrInvalidMeetingTime},
+	}
+
+	for _, test := range tests {
+		start, end, err := ParseMeetingTime(test.in)
+
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("ParseMeetingTime(%q) error = %v, want %v", test.in, err, test.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseMeetingTime(%q): %v", test.in, err)
+			continue
+		}
+
+		if got := start.Format("15:04"); got != test.start {
+			t.Errorf("ParseMeetingTime(%q) start = %s, want %s", test.in, got, test.start)
+		}
+
+		if got := end.Format("15:04"); got != test.end {
+			t.Errorf("ParseMeetingTime(%q) end = %s, want %s", test.in, got, test.end)
+		}
+	}
+}
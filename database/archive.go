@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Every statement matches the rows of courses whose generated term column
+// is the given term
+var archiveTermStatements = []string{
+	`INSERT OR REPLACE INTO archive_courses (term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits)
+		SELECT term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits FROM courses WHERE term = ?;`,
+	`INSERT OR REPLACE INTO archive_instructors (id, last_name, first_name, email, office, office_hours, term_crn)
+		SELECT ci.id, p.last_name, p.first_name, p.email, p.office, p.office_hours, ci.term_crn FROM course_instructors ci
+		JOIN instructor_profiles p ON p.id = ci.instructor_id WHERE ci.term_crn IN (SELECT term_crn FROM courses WHERE term = ?);`,
+	`INSERT OR REPLACE INTO archive_meetings (id, days, building, room, time, term_crn)
+		SELECT id, days, building, room, time, term_crn FROM meetings WHERE term_crn IN (SELECT term_crn FROM courses WHERE term = ?);`,
+	"DELETE FROM course_instructors WHERE term_crn IN (SELECT term_crn FROM courses WHERE term = ?);",
+	"DELETE FROM meetings WHERE term_crn IN (SELECT term_crn FROM courses WHERE term = ?);",
+	"DELETE FROM courses WHERE term = ?;",
+}
+
+// Moves every course of a term (and its instructors and meetings) out of
+// the active tables and into the archive tables in a single transaction.
+// The term must be a whole YYYYTT code such as 202410
+func ArchiveTerm(term string) error {
+	return defaultStore.ArchiveTerm(term)
+}
+
+func (s *Store) ArchiveTerm(term string) error {
+	term = strings.TrimSpace(term)
+	if !termPattern.MatchString(term) || len(term) != 6 {
+		return fmt.Errorf("term %q is not a YYYYTT code", term)
+	}
+
+	defer s.cache.clear()
+
+	transaction, err := s.beginContext(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range archiveTermStatements {
+		if _, err := transaction.Exec(statement, term); err != nil {
+			transaction.Rollback()
+			return fmt.Errorf("archiving term %s: %w", term, err)
+		}
+	}
+
+	return transaction.Commit()
+}
+
+func GetArchivedCourse(term_crn string) (*courseload.Course, error) {
+	return defaultStore.GetArchivedCourse(term_crn)
+}
+
+func (s *Store) GetArchivedCourse(term_crn string) (*courseload.Course, error) {
+	return s.loadCourse(context.Background(), SELECT_ARCHIVED_COURSE_STATEMENT, SELECT_ARCHIVED_INSTRUCTORS_STATEMENT, SELECT_ARCHIVED_MEETINGS_STATEMENT, term_crn)
+}
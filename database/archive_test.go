@@ -0,0 +1,51 @@
+package database
+
+import "testing"
+
+func TestArchiveTermRejectsPartialTerm(t *testing.T) {
+	useTestDatabase(t)
+
+	if err := InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, term := range []string{"", "2", "2024", "20241", "2024100", "20241000001", "2024AB"} {
+		if err := ArchiveTerm(term); err == nil {
+			t.Errorf("ArchiveTerm(%q) succeeded, want an error", term)
+		}
+	}
+
+	if _, err := GetCourse("20241000001"); err != nil {
+		t.Errorf("course archived by a rejected term: %v", err)
+	}
+}
+
+func TestArchiveTermMovesOneTerm(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, crn := range []string{"20241000001", "20241000002", "20242000001", "20251000001"} {
+		if err := InsertCourse(testCourse(crn, "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ArchiveTerm("202410"); err != nil {
+		t.Fatal(err)
+	}
+
+	for crn, archived := range map[string]bool{"20241000001": true, "20241000002": true, "20242000001": false, "20251000001": false} {
+		_, activeErr := GetCourse(crn)
+		course, archivedErr := GetArchivedCourse(crn)
+
+		if archived && (activeErr == nil || archivedErr != nil || len(course.Data.Meetings) != 1 || len(course.Data.Instructors) != 1) {
+			t.Errorf("course %s not archived with its children: %v, %v, %+v", crn, activeErr, archivedErr, course)
+		} else if !archived && (activeErr != nil || archivedErr == nil) {
+			t.Errorf("course %s of another term archived: %v, %v", crn, activeErr, archivedErr)
+		}
+	}
+
+	var meetings int
+	if err := defaultStore.db.QueryRow("SELECT COUNT(*) FROM meetings;").Scan(&meetings); err != nil || meetings != 2 {
+		t.Errorf("%d active meetings, %v, want the other terms' 2", meetings, err)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A course's seats at one scrape. Enrolled is Capacity less the seats
+// still available
+type AvailabilityPoint struct {
+	Time     time.Time
+	Enrolled int
+	Capacity int
+	Waitlist int
+}
+
+// Records every active course's current seat counts against scrape run
+// run, or against no run when run is 0. Run after an import so the
+// counts are the ones it just loaded
+func RecordAvailabilitySnapshot(run int64) error {
+	return defaultStore.RecordAvailabilitySnapshot(run)
+}
+
+func (s *Store) RecordAvailabilitySnapshot(run int64) error {
+	var run_id sql.NullInt64
+	if run != 0 {
+		run_id = sql.NullInt64{Int64: run, Valid: true}
+	}
+
+	_, err := s.execContext(context.Background(), "INSERT INTO availability_snapshots (term_crn, run_id, taken_at, seats_total, seats_available, waitlist_count) SELECT term_crn, ?, ?, seats_total, seats_available, waitlist_count FROM courses WHERE archived_at IS NULL;", run_id, time.Now().UTC())
+	return err
+}
+
+// The recorded seat counts of a course, oldest first. A course with no
+// snapshots yet has an empty history, while one that isn't stored at all
+// is ErrCourseNotFound
+func GetAvailabilityHistory(crn string) ([]AvailabilityPoint, error) {
+	return defaultStore.GetAvailabilityHistory(crn)
+}
+
+func (s *Store) GetAvailabilityHistory(crn string) ([]AvailabilityPoint, error) {
+	var term_crn string
+
+	err := s.queryRowContext(context.Background(), "SELECT term_crn FROM courses WHERE term_crn = ? COLLATE NOCASE;", crn).Scan(&term_crn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, crn)
+	} else if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queryContext(context.Background(), "SELECT taken_at, seats_total, seats_available, waitlist_count FROM availability_snapshots WHERE term_crn = ? ORDER BY taken_at, id;", term_crn)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	points := make([]AvailabilityPoint, 0)
+
+	for rows.Next() {
+		var point AvailabilityPoint
+		var seats_available int
+
+		if err = rows.Scan(&point.Time, &point.Capacity, &seats_available, &point.Waitlist); err != nil {
+			return nil, err
+		}
+
+		point.Enrolled = point.Capacity - seats_available
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAvailabilityHistory(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering")
+	course.Data.SeatsTotal, course.Data.SeatsAvailable = 30, 10
+
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertCourse(testCourse("20241012346", "COMP", "410", "Dropped")); err != nil {
+		t.Fatal(err)
+	}
+
+	if history, err := s.GetAvailabilityHistory("20241012345"); err != nil || len(history) != 0 {
+		t.Fatalf("history before any snapshot = %v, %v", history, err)
+	}
+
+	if err := s.RecordAvailabilitySnapshot(0); err != nil {
+		t.Fatal(err)
+	}
+
+	course.Data.SeatsAvailable, course.Data.WaitlistCount = 2, 3
+
+	if err := s.UpdateCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SoftDeleteCourse("20241012346"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RecordAvailabilitySnapshot(0); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.GetAvailabilityHistory("20241012345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AvailabilityPoint{{Enrolled: 20, Capacity: 30}, {Enrolled: 28, Capacity: 30, Waitlist: 3}}
+
+	if len(history) != len(want) {
+		t.Fatalf("got %d points, want %d", len(history), len(want))
+	}
+
+	for i, point := range history {
+		if point.Time.IsZero() {
+			t.Errorf("point %d has no time", i)
+		}
+
+		point.Time = want[i].Time
+		if point != want[i] {
+			t.Errorf("point %d = %+v, want %+v", i, point, want[i])
+		}
+	}
+
+	if dropped, err := s.GetAvailabilityHistory("20241012346"); err != nil || len(dropped) != 1 {
+		t.Errorf("soft deleted course history = %v, %v, want only the first snapshot", dropped, err)
+	}
+
+	if _, err = s.GetAvailabilityHistory("20249999999"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("unknown course error = %v, want ErrCourseNotFound", err)
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// Courses inserted together by InsertCourses. When anything in a batch
+// fails its courses are inserted one at a time instead, to find the bad ones
+const insertBatchSize = 100
+
+// SQLite's default limit on bound parameters before 3.32, kept to so a
+// multi-row insert works on any build
+const insertBatchParams = 999
+
+// Inserts courses in a single transaction using multi-row inserts. Unlike
+// BulkInsertCourses, a bad course doesn't stop the rest: errs holds each
+// course's error at its index, nil for the ones inserted. err is only set
+// when the transaction itself fails, in which case nothing is inserted
+func (s *Store) InsertCourses(courses []courseload.Course) (errs []error, err error) {
+	defer s.cache.clear()
+
+	err = withRetry(func() error {
+		errs = make([]error, len(courses))
+
+		transaction, err := s.beginContext(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for start := 0; start < len(courses); start += insertBatchSize {
+			batch := courses[start:min(start+insertBatchSize, len(courses))]
+
+			if err = s.insertBatchTx(transaction, batch, errs[start:]); err != nil {
+				transaction.Rollback()
+				return err
+			}
+		}
+
+		return transaction.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	failed := 0
+	for _, courseErr := range errs {
+		if courseErr != nil {
+			failed++
+		}
+	}
+
+	util.Log.Status(fmt.Sprintf("Inserted %d courses, %d failed", len(courses)-failed, failed))
+
+	// A failed checkpoint only means the WAL stays large until the next one
+	if err = s.Checkpoint(); err != nil {
+		util.Log.Error(fmt.Sprintf("Error checkpointing: %v", err))
+	}
+
+	return errs, nil
+}
+
+// Inserts batch under a savepoint, falling back to one course at a time
+// with each failure recorded in errs. Only errors from the savepoints
+// themselves are returned
+func (s *Store) insertBatchTx(transaction *sql.Tx, batch []courseload.Course, errs []error) error {
+	if _, err := transaction.Exec("SAVEPOINT batch;"); err != nil {
+		return err
+	}
+
+	if s.insertRowsTx(transaction, batch) != nil {
+		if _, err := transaction.Exec("ROLLBACK TO batch;"); err != nil {
+			return err
+		}
+
+		for i, course := range batch {
+			if _, err := transaction.Exec("SAVEPOINT course;"); err != nil {
+				return err
+			}
+
+			if errs[i] = s.insertCourseTx(transaction, course); errs[i] != nil {
+				if _, err := transaction.Exec("ROLLBACK TO course;"); err != nil {
+					return err
+				}
+			}
+
+			if _, err := transaction.Exec("RELEASE course;"); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := transaction.Exec("RELEASE batch;")
+	return err
+}
+
+// Inserts every course in batch along with its instructors and meetings,
+// a few statements per table rather than one per row
+func (s *Store) insertRowsTx(transaction *sql.Tx, batch []courseload.Course) error {
+	courseRows := make([][]interface{}, 0, len(batch))
+	linkRows := make([][]interface{}, 0, len(batch))
+	meetingRows := make([][]interface{}, 0, len(batch))
+
+	// Instructors usually teach several sections, so each is upserted once
+	profiles := make(map[courseload.Instructor]int64)
+
+	now := time.Now().UTC()
+
+	for _, course := range batch {
+		courseRows = append(courseRows, []interface{}{course.CRN, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description, course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.WaitlistCount, course.Data.Credits, now, now})
+
+		for _, instructor := range course.Data.Instructors {
+			instructor.Email = instructorEmail(instructor, course.CRN)
+
+			id, ok := profiles[instructor]
+			if !ok {
+				err := s.txQueryRow(transaction, UPSERT_INSTRUCTOR_PROFILE_STATEMENT, instructor.LastName, instructor.FirstName, instructor.Email, instructor.Office, instructor.OfficeHours).Scan(&id)
+				if err != nil {
+					return err
+				}
+
+				profiles[instructor] = id
+			}
+
+			linkRows = append(linkRows, []interface{}{course.CRN, id})
+		}
+
+		for _, meeting := range course.Data.Meetings {
+			meetingRows = append(meetingRows, []interface{}{meeting.Days, meeting.Building, meeting.Room, meeting.Time, course.CRN})
+		}
+	}
+
+	// Courses go first for the foreign keys of the other two
+	if err := execRowsTx(transaction, "INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, created_at, updated_at) VALUES ", courseRows); err != nil {
+		return err
+	}
+
+	if err := execRowsTx(transaction, "INSERT INTO course_instructors (term_crn, instructor_id) VALUES ", linkRows); err != nil {
+		return err
+	}
+
+	return execRowsTx(transaction, "INSERT INTO meetings (days, building, room, time, term_crn) VALUES ", meetingRows)
+}
+
+// Runs insert followed by as many rows as fit in insertBatchParams per
+// statement. Every row has the same number of columns
+func execRowsTx(transaction *sql.Tx, insert string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := len(rows[0])
+	row := "(" + placeholders(columns) + ")"
+	per := insertBatchParams / columns
+
+	for start := 0; start < len(rows); start += per {
+		group := rows[start:min(start+per, len(rows))]
+
+		args := make([]interface{}, 0, len(group)*columns)
+		for _, values := range group {
+			args = append(args, values...)
+		}
+
+		query := insert + strings.TrimSuffix(strings.Repeat(row+", ", len(group)), ", ") + ";"
+
+		if _, err := transaction.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
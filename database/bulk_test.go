@@ -0,0 +1,163 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestInsertCourses(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241000001", "COMP", "400", "Existing")); err != nil {
+		t.Fatal(err)
+	}
+
+	courses := []courseload.Course{
+		testCourse("20241000002", "COMP", "405", "Software Engineering", testMeeting("MWF", "9:10am-10:00am")),
+		testCourse("20241000001", "COMP", "400", "Already stored"),
+		testCourse("20241000003", "MATH", "425", "Calculus I", testMeeting("TR", "11:10am-12:30pm")),
+		testCourse("20241000002", "COMP", "405", "Listed twice"),
+	}
+
+	errs, err := s.InsertCourses(courses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		crn     string
+		wantErr bool
+		title   string
+	}{
+		{"20241000002", false, "Software Engineering"},
+		{"20241000001", true, "Existing"},
+		{"20241000003", false, "Calculus I"},
+		{"20241000002", true, "Software Engineering"},
+	}
+
+	for i, test := range tests {
+		if (errs[i] != nil) != test.wantErr {
+			t.Errorf("course %d (%s): error = %v, want error %v", i, test.crn, errs[i], test.wantErr)
+		}
+
+		course, err := s.GetCourse(test.crn)
+		if err != nil {
+			t.Errorf("GetCourse(%s): %v", test.crn, err)
+			continue
+		}
+
+		if course.Data.Title != test.title {
+			t.Errorf("course %s title = %q, want %q", test.crn, course.Data.Title, test.title)
+		}
+	}
+
+	if course, _ := s.GetCourse("20241000002"); course != nil && len(course.Data.Meetings) != 1 {
+		t.Errorf("duplicate CRN left %d meetings, want 1", len(course.Data.Meetings))
+	}
+
+	if count, _ := s.CountCourses(); count != 3 {
+		t.Errorf("got %d courses, want 3", count)
+	}
+}
+
+func TestInsertCoursesClosedStore(t *testing.T) {
+	s := newTestStore(t)
+	s.Close()
+
+	if _, err := s.InsertCourses([]courseload.Course{testCourse("20241000001", "COMP", "400", "Closed")}); !errors.Is(err, ErrDatabaseNotOpen) {
+		t.Errorf("InsertCourses on a closed store = %v, want ErrDatabaseNotOpen", err)
+	}
+}
+
+func TestBulkInsertCourses(t *testing.T) {
+	s := newTestStore(t)
+
+	courses := make([]courseload.Course, 500)
+	for i := range courses {
+		courses[i] = testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+	}
+
+	if err := s.BulkInsertCourses(courses); err != nil {
+		t.Fatal(err)
+	}
+
+	for table, want := range map[string]int{"courses": 500, "meetings": 500, "course_instructors": 500} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil || count != want {
+			t.Errorf("%s has %d rows, %v, want %d", table, count, err, want)
+		}
+	}
+
+	if course, err := s.GetCourse("20241000499"); err != nil || len(course.Data.Meetings) != 1 {
+		t.Errorf("GetCourse for the last course = %v, %v", course, err)
+	}
+}
+
+func TestBulkInsertCoursesRollsBack(t *testing.T) {
+	s := newTestStore(t)
+
+	courses := make([]courseload.Course, 100)
+	for i := range courses {
+		courses[i] = testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+	}
+
+	// A CRN repeated halfway through fails on the primary key
+	courses[50].CRN = courses[10].CRN
+
+	if err := s.BulkInsertCourses(courses); err == nil {
+		t.Fatal("BulkInsertCourses with a repeated CRN succeeded")
+	}
+
+	for _, table := range []string{"courses", "meetings", "course_instructors", "instructor_profiles"} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil || count != 0 {
+			t.Errorf("%s has %d rows, %v, after a failed bulk insert, want 0", table, count, err)
+		}
+	}
+}
+
+// BulkInsertCourses with the preparedStatements prepared once per Store
+// against parsing each statement on every course
+func BenchmarkBulkInsertCourses(b *testing.B) {
+	courses := make([]courseload.Course, 500)
+	for i := range courses {
+		courses[i] = testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+	}
+
+	prepared := preparedStatements
+
+	for _, test := range []struct {
+		name       string
+		statements map[string]bool
+	}{
+		{"prepared", prepared},
+		{"unprepared", map[string]bool{}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			preparedStatements = test.statements
+			defer func() { preparedStatements = prepared }()
+
+			s, err := NewStore(b.TempDir() + "/db.sqlite")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			defer s.Close()
+
+			for i := 0; i < b.N; i++ {
+				if err = s.BulkInsertCourses(courses); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				if _, err = s.db.Exec("DELETE FROM courses;"); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"container/list"
+	"slices"
+	"strings"
+	"sync"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// How many courses a Store keeps in memory for GetCourse unless told
+// otherwise by SetCacheSize
+const defaultCacheSize = 512
+
+// The most recently used courses by CRN. Courses are copied on the way in
+// and out so callers never share one
+type courseCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+
+	// Bumped by every invalidation, so a course loaded before a write
+	// isn't cached after it
+	generation uint64
+}
+
+type cacheEntry struct {
+	key    string
+	course courseload.Course
+}
+
+func newCourseCache(size int) *courseCache {
+	return &courseCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// CRNs are looked up without regard to case
+func cacheKey(term_crn string) string {
+	return strings.ToUpper(term_crn)
+}
+
+func copyCourse(course courseload.Course) courseload.Course {
+	course.Data.Instructors = slices.Clone(course.Data.Instructors)
+	course.Data.Meetings = slices.Clone(course.Data.Meetings)
+
+	if course.CreatedAt != nil {
+		created_at := *course.CreatedAt
+		course.CreatedAt = &created_at
+	}
+
+	if course.UpdatedAt != nil {
+		updated_at := *course.UpdatedAt
+		course.UpdatedAt = &updated_at
+	}
+
+	return course
+}
+
+func (c *courseCache) get(term_crn string) (*courseload.Course, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[cacheKey(term_crn)]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	course := copyCourse(element.Value.(*cacheEntry).course)
+
+	return &course, true
+}
+
+// The generation to hand back to put once a course has been loaded
+func (c *courseCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// Caches course unless the cache was invalidated since generation
+func (c *courseCache) put(course *courseload.Course, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 || generation != c.generation {
+		return
+	}
+
+	key := cacheKey(course.CRN)
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).course = copyCourse(*course)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, course: copyCourse(*course)})
+	c.evict()
+}
+
+// Drops the least recently used courses until at most size are left
+func (c *courseCache) evict() {
+	for c.order.Len() > max(c.size, 0) {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *courseCache) remove(term_crn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if element, ok := c.entries[cacheKey(term_crn)]; ok {
+		c.order.Remove(element)
+		delete(c.entries, cacheKey(term_crn))
+	}
+}
+
+func (c *courseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	clear(c.entries)
+}
+
+func (c *courseCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size = size
+	c.evict()
+}
+
+// Sets how many courses GetCourse keeps in memory, evicting the least
+// recently used ones if there are already more. 0 turns the cache off
+func SetCacheSize(n int) {
+	defaultStore.SetCacheSize(n)
+}
+
+func (s *Store) SetCacheSize(n int) {
+	s.cache.resize(n)
+}
+
+// Empties the course cache, for example after changing the database
+// outside this package
+func ClearCache() {
+	defaultStore.ClearCache()
+}
+
+func (s *Store) ClearCache() {
+	s.cache.clear()
+}
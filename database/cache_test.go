@@ -0,0 +1,101 @@
+package database
+
+import "testing"
+
+func TestCourseCache(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("MWF", "9:10am-10:00am"))
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changed behind the cache's back, so only a database read sees it
+	if _, err = s.db.Exec("UPDATE courses SET title = 'Changed' WHERE term_crn = ?;", course.CRN); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Data.Title != "Software Engineering" {
+		t.Errorf("second GetCourse read the database, title = %q", second.Data.Title)
+	}
+
+	// Callers get copies they can change freely
+	first.Data.Title = "Mutated"
+	first.Data.Meetings[0].Room = "Mutated"
+
+	if third, _ := s.GetCourse(course.CRN); third.Data.Title == "Mutated" || third.Data.Meetings[0].Room == "Mutated" {
+		t.Error("changing a returned course changed the cached one")
+	}
+
+	course.Data.Title = "Updated"
+	if err = s.UpdateCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if updated, _ := s.GetCourse(course.CRN); updated.Data.Title != "Updated" {
+		t.Errorf("GetCourse after UpdateCourse title = %q, want Updated", updated.Data.Title)
+	}
+
+	if err = s.DeleteCourse(course.CRN); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.GetCourse(course.CRN); err == nil {
+		t.Error("GetCourse found a deleted course in the cache")
+	}
+}
+
+func TestCourseCacheEviction(t *testing.T) {
+	tests := []struct {
+		size    int
+		put     []string
+		cached  []string
+		evicted []string
+	}{
+		{2, []string{"A", "B", "C"}, []string{"B", "C"}, []string{"A"}},
+		{1, []string{"A", "a"}, []string{"A"}, nil},
+		{0, []string{"A"}, nil, []string{"A"}},
+	}
+
+	for _, test := range tests {
+		cache := newCourseCache(test.size)
+
+		for _, crn := range test.put {
+			course := testCourse(crn, "COMP", "405", "Software Engineering")
+			cache.put(&course, cache.currentGeneration())
+		}
+
+		for _, crn := range test.cached {
+			if _, ok := cache.get(crn); !ok {
+				t.Errorf("size %d: %s was evicted", test.size, crn)
+			}
+		}
+
+		for _, crn := range test.evicted {
+			if _, ok := cache.get(crn); ok {
+				t.Errorf("size %d: %s is still cached", test.size, crn)
+			}
+		}
+	}
+
+	cache := newCourseCache(4)
+	generation := cache.currentGeneration()
+	cache.clear()
+
+	course := testCourse("A", "COMP", "405", "Stale")
+	cache.put(&course, generation)
+
+	if _, ok := cache.get("A"); ok {
+		t.Error("a course loaded before an invalidation was cached")
+	}
+}
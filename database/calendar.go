@@ -0,0 +1,126 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// iCalendar BYDAY values
+var icsWeekdays = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// How many weeks a meeting repeats for when no term dates are configured
+const defaultTermWeeks = 15
+
+// The user's schedule as an iCalendar feed with one weekly VEVENT per
+// meeting. Events repeat between the configured term dates, or for
+// defaultTermWeeks from today without them. TBA meetings are left out
+func ExportUserScheduleICS(email string) ([]byte, error) {
+	classes, err := GetUserClasses(email)
+	if err != nil {
+		return nil, err
+	}
+
+	courses, err := defaultStore.getCoursesByCRN(context.Background(), classes)
+	if err != nil {
+		return nil, err
+	}
+
+	termStart, termEnd := util.Config.Term.Start, util.Config.Term.End
+	if termStart.IsZero() {
+		now := time.Now()
+		termStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//hacknhbackend//Schedule//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+
+	for _, course := range courses {
+		for i, meeting := range course.Data.Meetings {
+			start, end, err := courseload.ParseMeetingTime(meeting.Time)
+			if err != nil {
+				continue
+			}
+
+			weekdays, err := courseload.ParseWeekdays(meeting.Days)
+			if err != nil || weekdays == 0 {
+				continue
+			}
+
+			var byDay []string
+			for _, day := range weekdays.Days() {
+				byDay = append(byDay, icsWeekdays[day])
+			}
+
+			// The first meeting day on or after the start of term
+			first := termStart
+			for !weekdays.Has(first.Weekday()) {
+				first = first.AddDate(0, 0, 1)
+			}
+
+			rule := "RRULE:FREQ=WEEKLY;BYDAY=" + strings.Join(byDay, ",")
+			if termEnd.IsZero() {
+				rule += fmt.Sprintf(";COUNT=%d", defaultTermWeeks*len(byDay))
+			} else {
+				rule += ";UNTIL=" + termEnd.Format("20060102") + "T235959"
+			}
+
+			writeICSLine(&buf, "BEGIN:VEVENT")
+			writeICSLine(&buf, fmt.Sprintf("UID:%s-%d@hacknhbackend.eparker.dev", course.CRN, i))
+			writeICSLine(&buf, "DTSTAMP:"+stamp)
+			writeICSLine(&buf, "DTSTART:"+first.Format("20060102")+start.Format("T150405"))
+			writeICSLine(&buf, "DTEND:"+first.Format("20060102")+end.Format("T150405"))
+			writeICSLine(&buf, rule)
+			writeICSLine(&buf, "SUMMARY:"+escapeICSText(course.Data.Title))
+			writeICSLine(&buf, "LOCATION:"+escapeICSText(strings.TrimSpace(meeting.Building+" "+meeting.Room)))
+			writeICSLine(&buf, "DESCRIPTION:"+escapeICSText(fmt.Sprintf("%s %s, CRN %s", course.Data.Subject, course.Data.Number, course.CRN)))
+			writeICSLine(&buf, "END:VEVENT")
+		}
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes(), nil
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// Writes a CRLF terminated content line, folding it so no line is longer
+// than 75 octets as RFC 5545 requires without splitting a UTF-8 sequence.
+// Continuation lines lose one octet to their leading space
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+
+	buf.WriteString(line + "\r\n")
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"hacknhbackend.eparker.dev/util"
+)
+
+// Enrolls the user in courses and returns their schedule as ICS
+func exportTestSchedule(t *testing.T, email string, courses ...string) string {
+	t.Helper()
+
+	if err := SetUserClasses(email, courses); err != nil {
+		t.Fatal(err)
+	}
+
+	ics, err := ExportUserScheduleICS(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(ics)
+}
+
+// The content lines of an ICS feed with folded lines joined back up
+func unfoldICS(ics string) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(ics, "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+func TestExportUserScheduleICS(t *testing.T) {
+	useTestDatabase(t)
+
+	if _, status := CreateUser("a@unh.edu", "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	saved := util.Config.Term
+	t.Cleanup(func() { util.Config.Term = saved })
+
+	util.Config.Term.Start = time.Date(2024, time.September, 3, 0, 0, 0, 0, time.UTC)
+	util.Config.Term.End = time.Date(2024, time.December, 13, 0, 0, 0, 0, time.UTC)
+
+	for _, course := range []struct {
+		crn, title string
+		meetings   []string
+	}{
+		{"20241000001", "Data Structures", []string{"MWF", "TR"}},
+		{"20241000002", "Independent Study", []string{"TBA"}},
+	} {
+		c := testCourse(course.crn, "COMP", "400", course.title)
+		for _, days := range course.meetings {
+			if days == "TBA" {
+				c.Data.Meetings = append(c.Data.Meetings, testMeeting("TBA", "TBA"))
+			} else {
+				c.Data.Meetings = append(c.Data.Meetings, testMeeting(days, "9:10am-10:00am"))
+			}
+		}
+
+		if err := InsertCourse(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := unfoldICS(exportTestSchedule(t, "a@unh.edu", "20241000001", "20241000002"))
+
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("feed runs from %q to %q, want a VCALENDAR", lines[0], lines[len(lines)-1])
+	}
+
+	events := map[string][]string{}
+	var uid string
+
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, "UID:"); ok {
+			uid = value
+		} else if line == "END:VEVENT" {
+			uid = ""
+		} else if uid != "" {
+			events[uid] = append(events[uid], line)
+		}
+	}
+
+	if count := strings.Count(strings.Join(lines, "\n"), "BEGIN:VEVENT"); count != 2 || len(events) != 2 {
+		t.Fatalf("got %d VEVENTs %v, want one per scheduled meeting and none for TBA", count, events)
+	}
+
+	for uid, want := range map[string][]string{
+		"20241000001-0@hacknhbackend.eparker.dev": {
+			"DTSTART:20240904T091000",
+			"DTEND:20240904T100000",
+			"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20241213T235959",
+		},
+		"20241000001-1@hacknhbackend.eparker.dev": {
+			"DTSTART:20240903T091000",
+			"DTEND:20240903T100000",
+			"RRULE:FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20241213T235959",
+		},
+	} {
+		for _, line := range want {
+			found := false
+			for _, got := range events[uid] {
+				found = found || got == line
+			}
+
+			if !found {
+				t.Errorf("event %s = %v, missing %q", uid, events[uid], line)
+			}
+		}
+	}
+
+	util.Config.Term.End = time.Time{}
+
+	ics := exportTestSchedule(t, "a@unh.edu", "20241000001")
+	if !strings.Contains(ics, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=45\r\n") {
+		t.Errorf("without a term end the MWF rule should repeat for %d weeks:\n%s", defaultTermWeeks, ics)
+	}
+}
+
+func TestExportUserScheduleICSEscapesAndFolds(t *testing.T) {
+	useTestDatabase(t)
+
+	if _, status := CreateUser("a@unh.edu", "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	title := "Special Topics; Compilers, Interpreters\\Runtimes: " + strings.Repeat("x", 160) + strings.Repeat("é", 40)
+
+	course := testCourse("20241000001", "COMP", "400", title, testMeeting("MWF", "9:10am-10:00am"))
+	if err := InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	ics := exportTestSchedule(t, "a@unh.edu", "20241000001")
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets, want at most 75: %q", len(line), line)
+		}
+
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a UTF-8 sequence: %q", line)
+		}
+
+		if strings.Contains(line, "\n") {
+			t.Errorf("bare line feed in %q", line)
+		}
+	}
+
+	want := `SUMMARY:Special Topics\; Compilers\, Interpreters\\Runtimes: ` + strings.Repeat("x", 160) + strings.Repeat("é", 40)
+
+	found := false
+	for _, line := range unfoldICS(ics) {
+		found = found || line == want
+	}
+
+	if !found {
+		t.Errorf("no escaped SUMMARY %q in:\n%s", want, ics)
+	}
+
+	if got := escapeICSText("one\ntwo\r\nthree"); got != `one\ntwo\nthree` {
+		t.Errorf("escapeICSText = %q, want newlines as \\n", got)
+	}
+}
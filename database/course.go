@@ -1,8 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"time"
 
 	"hacknhbackend.eparker.dev/courseload"
@@ -10,20 +15,59 @@ import (
 )
 
 func CourseUpdates() {
+	defer defaultStore.cache.clear()
+
+	run, err := StartScrapeRun()
+	if err != nil {
+		util.Log.Error(fmt.Sprintf("Error recording scrape run: %v", err))
+	}
+
+	var stats ScrapeRunStats
+
+	defer func() {
+		if run == 0 {
+			return
+		}
+
+		if err := FinishScrapeRun(run, stats); err != nil {
+			util.Log.Error(fmt.Sprintf("Error recording scrape run: %v", err))
+		}
+	}()
+
 	start := time.Now()
 	courses := courseload.LoadCourses()
 	util.Log.Basic(fmt.Sprintf("Loaded %d courses in %v", len(courses), time.Since(start)))
 
+	if stats = applyCourseUpdates(courses); stats.Error != nil {
+		return
+	}
+
+	if err = RecordAvailabilitySnapshot(run); err != nil {
+		util.Log.Error(fmt.Sprintf("Error recording availability snapshot: %v", err))
+	}
+
+	if err = AnalyzeIndexes(); err != nil {
+		util.Log.Error(fmt.Sprintf("Error analyzing indexes: %v", err))
+	}
+}
+
+// Brings the stored courses in line with courses, the full feed. Each
+// count only includes the courses actually written, and a course that
+// fails is logged and skipped. Error is only set when the import as a whole
+// failed
+func applyCourseUpdates(courses []courseload.Course) (stats ScrapeRunStats) {
 	crns, err := GetCourseCRNs()
 
 	if err != nil {
 		util.Log.Error(fmt.Sprintf("Error getting course CRNS: %v\n\n> Will Override", err))
 
 		for _, course := range courses {
-			InsertCourse(course)
+			if InsertCourse(course) == nil {
+				stats.Added++
+			}
 		}
 
-		return
+		return stats
 	}
 
 	// Map to map[string]bool
@@ -33,14 +77,50 @@ func CourseUpdates() {
 		crnsMap[course.CRN] = 1
 	}
 
-	var inserts, deletes int = 0, 0
+	var inserts, updates, deletes, failures int = 0, 0, 0, 0
 
+	// Courses missing from the feed are soft deleted, so one that was only
+	// dropped for a while keeps its identity when it comes back
 	for _, crn := range crns {
 		if _, ok := crnsMap[crn]; !ok {
-			DeleteCourse(crn)
-			deletes++
+			if err := SoftDeleteCourse(crn); err != nil {
+				util.Log.Error(fmt.Sprintf("Error soft deleting course %s: %v", crn, err))
+				failures++
+			} else {
+				deletes++
+			}
+		} else {
+			crnsMap[crn] = 2
+		}
+	}
+
+	archived, err := defaultStore.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE archived_at IS NOT NULL;")
+	if err != nil {
+		util.Log.Error(fmt.Sprintf("Error getting archived course CRNs: %v", err))
+	}
+
+	for _, crn := range archived {
+		if crnsMap[crn] != 1 {
+			continue
+		}
+
+		if err := RestoreCourse(crn); err != nil {
+			util.Log.Error(fmt.Sprintf("Error restoring course %s: %v", crn, err))
+			failures++
 		} else {
 			crnsMap[crn] = 2
+			inserts++
+		}
+	}
+
+	// Courses already stored are only rewritten when the feed changed them
+	changed := make(map[string]bool)
+
+	if _, _, changedCRNs, err := DiffCourses(courses); err != nil {
+		util.Log.Error(fmt.Sprintf("Error comparing courses: %v", err))
+	} else {
+		for _, crn := range changedCRNs {
+			changed[crn] = true
 		}
 	}
 
@@ -49,24 +129,39 @@ func CourseUpdates() {
 
 	if err != nil {
 		util.Log.Error(fmt.Sprintf("Error starting transaction: %v", err))
-		return
+		stats.Removed, stats.Error = deletes, err
+		return stats
 	}
 
 	for _, course := range courses {
-		if crnsMap[course.CRN] == 1 {
-			// InsertCourse(course)
+		var write func(transaction *sql.Tx, course courseload.Course) error
+		var count *int
 
-			transaction.Exec(INSERT_COURSE_STATEMENT, course.CRN, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description)
-
-			for _, instructor := range course.Data.Instructors {
-				transaction.Exec(INSERT_INSTUCTOR_STATEMENT, instructor.LastName, instructor.FirstName, instructor.Email, course.CRN)
-			}
+		if crnsMap[course.CRN] == 1 {
+			write, count = defaultStore.insertCourseTx, &inserts
+		} else if changed[course.CRN] {
+			write, count = defaultStore.updateCourseTx, &updates
+		} else {
+			continue
+		}
 
-			for _, meeting := range course.Data.Meetings {
-				transaction.Exec(INSERT_MEETING_STATEMENT, meeting.Days, meeting.Building, meeting.Room, meeting.Time, course.CRN)
-			}
+		// A savepoint per course keeps one bad course from leaving a
+		// partial write behind without failing the whole import
+		writeErr, err := savepointTx(transaction, func() error {
+			return write(transaction, course)
+		})
+		if err != nil {
+			util.Log.Error(fmt.Sprintf("Error writing course %s: %v", course.CRN, err))
+			transaction.Rollback()
+			stats.Removed, stats.Error = deletes, err
+			return stats
+		}
 
-			inserts++
+		if writeErr != nil {
+			util.Log.Error(fmt.Sprintf("Error writing course: %v", writeErr))
+			failures++
+		} else {
+			*count++
 		}
 	}
 
@@ -74,90 +169,396 @@ func CourseUpdates() {
 
 	if err != nil {
 		util.Log.Error(fmt.Sprintf("Error committing transaction: %v", err))
-		return
+		stats.Removed, stats.Error = deletes, err
+		return stats
+	}
+
+	stats.Added, stats.Updated, stats.Removed = inserts, updates, deletes
+
+	util.Log.Status(fmt.Sprintf("Inserted %d courses, updated %d courses, deleted %d courses, %d failed", inserts, updates, deletes, failures))
+
+	return stats
+}
+
+// Runs write under a savepoint, undoing whatever it did if it fails.
+// writeErr is write's own error, which leaves transaction usable, while
+// err means the savepoint itself failed and transaction has to be rolled
+// back
+func savepointTx(transaction *sql.Tx, write func() error) (writeErr, err error) {
+	if _, err = transaction.Exec("SAVEPOINT course;"); err != nil {
+		return nil, fmt.Errorf("creating savepoint: %w", err)
+	}
+
+	if writeErr = write(); writeErr != nil {
+		if _, err = transaction.Exec("ROLLBACK TO course;"); err != nil {
+			return writeErr, fmt.Errorf("rolling back to savepoint: %w", err)
+		}
+	}
+
+	if _, err = transaction.Exec("RELEASE course;"); err != nil {
+		return writeErr, fmt.Errorf("releasing savepoint: %w", err)
 	}
 
-	util.Log.Status(fmt.Sprintf("Inserted %d courses, deleted %d courses", inserts, deletes))
+	return writeErr, nil
+}
+
+// Inserts a course with its instructors and meetings in one transaction,
+// so a failure part way through leaves nothing behind
+func (s *Store) InsertCourse(course courseload.Course) error {
+	return s.InsertCourseContext(context.Background(), course)
+}
+
+func (s *Store) InsertCourseContext(ctx context.Context, course courseload.Course) error {
+	// Instructor profiles are shared between courses, so writing one
+	// course's instructors can change how others read
+	defer s.cache.clear()
+
+	return withRetryContext(ctx, func() error {
+		transaction, err := s.beginContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err = s.insertCourseTx(transaction, course); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		return transaction.Commit()
+	})
 }
 
-func InsertCourse(course courseload.Course) error {
-	err := QueuedExec(INSERT_COURSE_STATEMENT, course.CRN, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description)
+// Inserts every course in a single transaction. Nothing is inserted if any
+// course fails
+func (s *Store) BulkInsertCourses(courses []courseload.Course) error {
+	defer s.cache.clear()
+
+	err := withRetry(func() error {
+		transaction, err := s.beginContext(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, course := range courses {
+			if err = s.insertCourseTx(transaction, course); err != nil {
+				transaction.Rollback()
+				return err
+			}
+		}
+
+		return transaction.Commit()
+	})
 	if err != nil {
 		return err
 	}
 
+	util.Log.Status(fmt.Sprintf("Bulk inserted %d courses", len(courses)))
+
+	// A failed checkpoint only means the WAL stays large until the next one
+	if err = s.Checkpoint(); err != nil {
+		util.Log.Error(fmt.Sprintf("Error checkpointing: %v", err))
+	}
+
+	return nil
+}
+
+// Inserts the course row, its instructors and its meetings on transaction,
+// stopping at the first failure
+func (s *Store) insertCourseTx(transaction *sql.Tx, course courseload.Course) error {
+	now := time.Now().UTC()
+
+	_, err := s.txExec(transaction, INSERT_COURSE_STATEMENT, course.CRN, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description, course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.WaitlistCount, course.Data.Credits, now, now)
+	if err != nil {
+		return fmt.Errorf("inserting course %s: %w", course.CRN, err)
+	}
+
+	return s.insertCourseChildrenTx(transaction, course)
+}
+
+func (s *Store) insertCourseChildrenTx(transaction *sql.Tx, course courseload.Course) error {
 	for _, instructor := range course.Data.Instructors {
-		err := QueuedExec(INSERT_INSTUCTOR_STATEMENT, instructor.LastName, instructor.FirstName, instructor.Email, course.CRN)
+		var id int64
+
+		email := instructorEmail(instructor, course.CRN)
+
+		err := s.txQueryRow(transaction, UPSERT_INSTRUCTOR_PROFILE_STATEMENT, instructor.LastName, instructor.FirstName, email, instructor.Office, instructor.OfficeHours).Scan(&id)
+		if err == nil {
+			_, err = s.txExec(transaction, INSERT_COURSE_INSTRUCTOR_STATEMENT, course.CRN, id)
+		}
+
 		if err != nil {
-			return err
+			return fmt.Errorf("inserting instructor %s, %s for course %s: %w", instructor.LastName, instructor.FirstName, course.CRN, err)
 		}
 	}
 
 	for _, meeting := range course.Data.Meetings {
-		err := QueuedExec(INSERT_MEETING_STATEMENT, meeting.Days, meeting.Building, meeting.Room, meeting.Time, course.CRN)
+		_, err := s.txExec(transaction, INSERT_MEETING_STATEMENT, meeting.Days, meeting.Building, meeting.Room, meeting.Time, course.CRN)
 		if err != nil {
-			return err
+			return fmt.Errorf("inserting meeting %s %s for course %s: %w", meeting.Days, meeting.Time, course.CRN, err)
 		}
 	}
 
 	return nil
 }
 
-func DeleteCourse(term_crn string) error {
-	err := QueuedExec("DELETE FROM courses WHERE term_crn = ?;", term_crn)
-	if err != nil {
+// Swaps a course's instructors and meetings for those in course
+func (s *Store) replaceCourseChildrenTx(transaction *sql.Tx, course courseload.Course) error {
+	for _, table := range []string{"course_instructors", "meetings"} {
+		if _, err := transaction.Exec("DELETE FROM "+table+" WHERE term_crn = ?;", course.CRN); err != nil {
+			return fmt.Errorf("clearing %s for course %s: %w", table, course.CRN, err)
+		}
+	}
+
+	return s.insertCourseChildrenTx(transaction, course)
+}
+
+// Replaces an existing course's data, including its instructors and
+// meetings. Returns ErrCourseNotFound rather than creating the course
+func (s *Store) UpdateCourse(course courseload.Course) error {
+	return s.UpdateCourseContext(context.Background(), course)
+}
+
+func (s *Store) UpdateCourseContext(ctx context.Context, course courseload.Course) error {
+	defer s.cache.clear()
+
+	return withRetryContext(ctx, func() error {
+		transaction, err := s.beginContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err = s.updateCourseTx(transaction, course); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		return transaction.Commit()
+	})
+}
+
+func (s *Store) updateCourseTx(transaction *sql.Tx, course courseload.Course) error {
+	// Use the CRN as stored so child rows match the course row's casing
+	err := transaction.QueryRow("SELECT term_crn FROM courses WHERE term_crn = ? COLLATE NOCASE;", course.CRN).Scan(&course.CRN)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrCourseNotFound, course.CRN)
+	} else if err != nil {
 		return err
 	}
 
-	err = QueuedExec("DELETE FROM instructors WHERE term_crn = ?;", term_crn)
+	_, err = transaction.Exec(UPDATE_COURSE_STATEMENT, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description, course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.WaitlistCount, course.Data.Credits, time.Now().UTC(), course.CRN)
 	if err != nil {
+		return fmt.Errorf("updating course %s: %w", course.CRN, err)
+	}
+
+	return s.replaceCourseChildrenTx(transaction, course)
+}
+
+// Inserts the course, or replaces it and its instructors and meetings when
+// the CRN already exists, so loading the same data twice is harmless
+func (s *Store) InsertOrUpdateCourse(course courseload.Course) error {
+	defer s.cache.clear()
+
+	return withRetry(func() error {
+		transaction, err := s.beginContext(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if err = s.upsertCourseTx(transaction, course); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		return transaction.Commit()
+	})
+}
+
+func (s *Store) upsertCourseTx(transaction *sql.Tx, course courseload.Course) error {
+	// An existing course keeps the CRN's stored casing
+	err := transaction.QueryRow("SELECT term_crn FROM courses WHERE term_crn = ? COLLATE NOCASE;", course.CRN).Scan(&course.CRN)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
-	err = QueuedExec("DELETE FROM meetings WHERE term_crn = ?;", term_crn)
+	now := time.Now().UTC()
+
+	_, err = transaction.Exec(UPSERT_COURSE_STATEMENT, course.CRN, course.Data.Title, course.Data.Subject, course.Data.Number, course.Data.SectionNum, course.Data.Description, course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.WaitlistCount, course.Data.Credits, now, now)
+	if err != nil {
+		return fmt.Errorf("upserting course %s: %w", course.CRN, err)
+	}
+
+	return s.replaceCourseChildrenTx(transaction, course)
+}
+
+// Deletes a course along with its instructors, meetings and watches. It is
+// a single statement, so it is as atomic as a transaction would make it
+func (s *Store) DeleteCourse(term_crn string) error {
+	return s.DeleteCourseContext(context.Background(), term_crn)
+}
+
+func (s *Store) DeleteCourseContext(ctx context.Context, term_crn string) error {
+	defer s.cache.remove(term_crn)
+
+	// The course's instructors, meetings and watches go with it through
+	// ON DELETE CASCADE
+	_, err := s.execContext(ctx, "DELETE FROM courses WHERE term_crn = ? COLLATE NOCASE;", term_crn)
+	return err
+}
+
+// Hides a course from every lookup and query without deleting it, so it
+// can be brought back by RestoreCourse. PurgeArchived removes it for good
+func (s *Store) SoftDeleteCourse(term_crn string) error {
+	defer s.cache.remove(term_crn)
+
+	_, err := s.execContext(context.Background(), "UPDATE courses SET archived_at = ? WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;", time.Now().UTC(), term_crn)
+	return err
+}
+
+// Undoes SoftDeleteCourse. Restoring a course that isn't soft deleted does
+// nothing
+func (s *Store) RestoreCourse(term_crn string) error {
+	result, err := s.execContext(context.Background(), "UPDATE courses SET archived_at = NULL WHERE term_crn = ? COLLATE NOCASE;", term_crn)
 	if err != nil {
 		return err
 	}
 
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrCourseNotFound, term_crn)
+	}
+
 	return nil
 }
 
-func GetCourse(term_crn string) (*courseload.Course, error) {
-	row := QueuedQueryRow(SELECT_COUSE_STATEMENT, term_crn)
+// Deletes the courses soft deleted before the given time, along with their
+// instructors and meetings
+func (s *Store) PurgeArchived(before time.Time) error {
+	return withRetry(func() error {
+		transaction, err := s.beginContext(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range purgeArchivedStatements {
+			if _, err = transaction.Exec(statement, before.UTC()); err != nil {
+				transaction.Rollback()
+				return fmt.Errorf("purging archived courses: %w", err)
+			}
+		}
+
+		return transaction.Commit()
+	})
+}
+
+// Children go first while their course still says when it was archived
+var purgeArchivedStatements = []string{
+	"DELETE FROM course_instructors WHERE term_crn IN (SELECT term_crn FROM courses WHERE archived_at < ?);",
+	"DELETE FROM meetings WHERE term_crn IN (SELECT term_crn FROM courses WHERE archived_at < ?);",
+	"DELETE FROM courses WHERE archived_at < ?;",
+}
+
+func (s *Store) GetCourse(term_crn string) (*courseload.Course, error) {
+	return s.GetCourseContext(context.Background(), term_crn)
+}
+
+func (s *Store) GetCourseContext(ctx context.Context, term_crn string) (*courseload.Course, error) {
+	if course, ok := s.cache.get(term_crn); ok {
+		return course, nil
+	}
+
+	generation := s.cache.currentGeneration()
+
+	course, err := s.loadCourse(ctx, SELECT_COUSE_STATEMENT, SELECT_INSTRUCTORS_STATEMENT, SELECT_MEETINGS_STATEMENT, term_crn)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.put(course, generation)
+
+	return course, nil
+}
+
+// A course's own row, with empty instructors and meetings
+func (s *Store) loadCourseRow(ctx context.Context, courseStatement, term_crn string) (*courseload.Course, error) {
+	row := s.queryRowContext(ctx, courseStatement, term_crn)
 
 	var title, subject_code, course_number, section_number, description string
-	err := row.Scan(&term_crn, &title, &subject_code, &course_number, &section_number, &description)
+	var seats_total, seats_available, waitlist_count int
+	var credits float64
+	var created_at, updated_at sql.NullTime
+	err := row.Scan(&term_crn, &title, &subject_code, &course_number, &section_number, &description, &seats_total, &seats_available, &waitlist_count, &credits, &created_at, &updated_at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, term_crn)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &courseload.Course{
+		CRN: term_crn,
+		Data: courseload.CourseData{
+			Title:       title,
+			Subject:     subject_code,
+			Number:      course_number,
+			Description: description,
+			Instructors: make([]courseload.Instructor, 0),
+			Meetings:    make([]courseload.Meeting, 0),
+			SectionNum:  section_number,
+
+			SeatsTotal:     seats_total,
+			SeatsAvailable: seats_available,
+			WaitlistCount:  waitlist_count,
+
+			Credits: credits,
+		},
+		CreatedAt: nullTime(created_at),
+		UpdatedAt: nullTime(updated_at),
+	}, nil
+}
+
+// Loads a single course using the given course, instructor and meeting
+// statements so the active and archive tables share the same hydration
+func (s *Store) loadCourse(ctx context.Context, courseStatement, instructorsStatement, meetingsStatement, term_crn string) (*courseload.Course, error) {
+	course, err := s.loadCourseRow(ctx, courseStatement, term_crn)
 	if err != nil {
 		return nil, err
 	}
 
+	term_crn = course.CRN
+
 	instructors := make([]courseload.Instructor, 0)
-	rows, err := QueuedQuery(SELECT_INSTRUCTORS_STATEMENT, term_crn)
+	rows, err := s.queryContext(ctx, instructorsStatement, term_crn)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		var id int
-		var last_name, first_name, email string
-		err = rows.Scan(&id, &last_name, &first_name, &email)
+		var last_name, first_name, email, office, office_hours string
+		err = rows.Scan(&id, &last_name, &first_name, &email, &office, &office_hours)
 		if err != nil {
 			return nil, err
 		}
 
 		instructors = append(instructors, courseload.Instructor{
-			LastName:  last_name,
-			FirstName: first_name,
-			Email:     email,
+			LastName:    last_name,
+			FirstName:   first_name,
+			Email:       email,
+			Office:      office,
+			OfficeHours: office_hours,
 		})
 	}
 
 	meetings := make([]courseload.Meeting, 0)
-	rows, err = QueuedQuery(SELECT_MEETINGS_STATEMENT, term_crn)
+	rows, err = s.queryContext(ctx, meetingsStatement, term_crn)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		var id int
 		var days, building, room, time string
@@ -167,6 +568,7 @@ func GetCourse(term_crn string) (*courseload.Course, error) {
 		}
 
 		meetings = append(meetings, courseload.Meeting{
+			ID:       id,
 			Days:     days,
 			Building: building,
 			Room:     room,
@@ -174,86 +576,579 @@ func GetCourse(term_crn string) (*courseload.Course, error) {
 		})
 	}
 
-	return &courseload.Course{
-		CRN: term_crn,
-		Data: courseload.CourseData{
-			Title:       title,
-			Subject:     subject_code,
-			Number:      course_number,
-			Description: description,
-			Instructors: instructors,
-			Meetings:    meetings,
-			SectionNum:  section_number,
-		},
-	}, nil
+	course.Data.Instructors = instructors
+	course.Data.Meetings = meetings
+
+	return course, nil
+}
+
+// A course's own fields without its instructors and meetings, which are
+// left empty, in a single query. For lists that only show titles and
+// numbers
+func (s *Store) GetCourseSummary(term_crn string) (*courseload.Course, error) {
+	if course, ok := s.cache.get(term_crn); ok {
+		course.Data.Instructors = make([]courseload.Instructor, 0)
+		course.Data.Meetings = make([]courseload.Meeting, 0)
+		return course, nil
+	}
+
+	return s.loadCourseRow(context.Background(), SELECT_COUSE_STATEMENT, term_crn)
+}
+
+func (s *Store) GetCourseCRNs() ([]string, error) {
+	return s.GetCourseCRNsContext(context.Background())
+}
+
+func (s *Store) GetCourseCRNsContext(ctx context.Context) ([]string, error) {
+	return s.selectCRNs(ctx, "SELECT term_crn FROM courses WHERE archived_at IS NULL;")
 }
 
-func GetCourseCRNs() ([]string, error) {
-	rows, err := QueuedQuery("SELECT term_crn FROM courses;")
+func (s *Store) CountCourses() (int, error) {
+	var count int
+
+	err := s.queryRowContext(context.Background(), "SELECT COUNT(*) FROM courses WHERE archived_at IS NULL;").Scan(&count)
+	return count, err
+}
+
+// The number of courses in each subject
+func (s *Store) CountCoursesBySubject() (map[string]int, error) {
+	rows, err := s.queryContext(context.Background(), "SELECT subject_code, COUNT(*) FROM courses WHERE archived_at IS NULL GROUP BY subject_code;")
 	if err != nil {
 		return nil, err
 	}
 
-	courses := make([]string, 0)
+	defer rows.Close()
+
+	counts := make(map[string]int)
 
 	for rows.Next() {
-		var term_crn string
-		err = rows.Scan(&term_crn)
+		var subject_code string
+		var count int
+		if err := rows.Scan(&subject_code, &count); err != nil {
+			return nil, err
+		}
+
+		counts[subject_code] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// CRNs ordered by CRN, skipping offset and returning at most limit
+func (s *Store) GetCourseCRNsPage(limit, offset int) ([]string, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	return s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE archived_at IS NULL ORDER BY term_crn LIMIT ? OFFSET ?;", limit, offset)
+}
+
+// CRNs starting with prefix, sorted, at most limit of them. % and _ in the
+// prefix match themselves rather than acting as wildcards
+func (s *Store) GetCourseCRNsByPrefix(prefix string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	return s.selectCRNs(context.Background(), `SELECT term_crn FROM courses WHERE term_crn LIKE ? || '%' ESCAPE '\' AND archived_at IS NULL ORDER BY term_crn LIMIT ?;`, escapeLike(prefix), limit)
+}
+
+// A course picked at random, for a course of the day. Rather than sorting
+// the whole table by RANDOM(), a random offset into the CRN index is taken.
+// Returns ErrCourseNotFound when there are no courses
+func (s *Store) GetRandomCourse() (*courseload.Course, error) {
+	// A course deleted between counting and picking leaves the offset past
+	// the end, in which case a new one is drawn
+	for range 3 {
+		count, err := s.CountCourses()
 		if err != nil {
 			return nil, err
 		}
 
-		courses = append(courses, term_crn)
+		if count == 0 {
+			break
+		}
+
+		crns, err := s.GetCourseCRNsPage(1, rand.IntN(count))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(crns) == 0 {
+			continue
+		}
+
+		course, err := s.GetCourse(crns[0])
+		if errors.Is(err, ErrCourseNotFound) {
+			continue
+		}
+
+		return course, err
+	}
+
+	return nil, fmt.Errorf("%w: no courses", ErrCourseNotFound)
+}
+
+// Escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Hydrates every course in crns, in the same order, with three queries
+// rather than three per course. CRNs without a course are skipped, so the
+// result is shorter than crns when any are missing
+func (s *Store) GetCourses(crns []string) ([]courseload.Course, error) {
+	return s.GetCoursesContext(context.Background(), crns)
+}
+
+func (s *Store) GetCoursesContext(ctx context.Context, crns []string) ([]courseload.Course, error) {
+	return s.getCoursesByCRN(ctx, crns)
+}
+
+// SQLite limits the number of bound parameters per statement
+const hydrateBatchSize = 500
+
+// Runs a query, either through a Store's queue or on a transaction
+type queryFunc func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+
+// Hydrates courses in the order of crns using three queries per batch
+// instead of three per course. CRNs without a course are skipped
+func (s *Store) getCoursesByCRN(ctx context.Context, crns []string) ([]courseload.Course, error) {
+	return loadCourses(ctx, s.queryContext, crns)
+}
+
+// getCoursesByCRN with the queries run by query, so a transaction can
+// read the courses it is about to write
+func loadCourses(ctx context.Context, query queryFunc, crns []string) ([]courseload.Course, error) {
+	found := make(map[string]*courseload.Course, len(crns))
+
+	for start := 0; start < len(crns); start += hydrateBatchSize {
+		if err := hydrateCourses(ctx, query, crns[start:min(start+hydrateBatchSize, len(crns))], found); err != nil {
+			return nil, err
+		}
+	}
+
+	courses := make([]courseload.Course, 0, len(crns))
+
+	for _, crn := range crns {
+		if course, ok := found[strings.ToUpper(crn)]; ok {
+			courses = append(courses, *course)
+		}
 	}
 
 	return courses, nil
 }
 
+// Loads a batch of courses with their instructors and meetings into found,
+// keyed by the uppercased CRN
+func hydrateCourses(ctx context.Context, query queryFunc, crns []string, found map[string]*courseload.Course) error {
+	args := make([]interface{}, len(crns))
+
+	for i, crn := range crns {
+		args[i] = crn
+	}
+
+	rows, err := query(ctx, fmt.Sprintf(SELECT_COURSES_IN_STATEMENT, placeholders(len(crns))), args...)
+	if err != nil {
+		return err
+	}
+
+	stored := make([]interface{}, 0, len(crns))
+
+	for rows.Next() {
+		course := courseload.Course{
+			Data: courseload.CourseData{
+				Instructors: make([]courseload.Instructor, 0),
+				Meetings:    make([]courseload.Meeting, 0),
+			},
+		}
+
+		var created_at, updated_at sql.NullTime
+
+		err = rows.Scan(&course.CRN, &course.Data.Title, &course.Data.Subject, &course.Data.Number, &course.Data.SectionNum, &course.Data.Description, &course.Data.SeatsTotal, &course.Data.SeatsAvailable, &course.Data.WaitlistCount, &course.Data.Credits, &created_at, &updated_at)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		course.CreatedAt, course.UpdatedAt = nullTime(created_at), nullTime(updated_at)
+
+		found[strings.ToUpper(course.CRN)] = &course
+		stored = append(stored, course.CRN)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil || len(stored) == 0 {
+		return err
+	}
+
+	// Child rows carry the CRN exactly as the course row stores it
+	rows, err = query(ctx, fmt.Sprintf(SELECT_INSTRUCTORS_IN_STATEMENT, placeholders(len(stored))), stored...)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var term_crn string
+		var instructor courseload.Instructor
+
+		if err = rows.Scan(&term_crn, &instructor.LastName, &instructor.FirstName, &instructor.Email, &instructor.Office, &instructor.OfficeHours); err != nil {
+			rows.Close()
+			return err
+		}
+
+		course := found[strings.ToUpper(term_crn)]
+		course.Data.Instructors = append(course.Data.Instructors, instructor)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = query(ctx, fmt.Sprintf(SELECT_MEETINGS_IN_STATEMENT, placeholders(len(stored))), stored...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var term_crn string
+		var meeting courseload.Meeting
+
+		if err = rows.Scan(&term_crn, &meeting.ID, &meeting.Days, &meeting.Building, &meeting.Room, &meeting.Time); err != nil {
+			return err
+		}
+
+		course := found[strings.ToUpper(term_crn)]
+		course.Data.Meetings = append(course.Data.Meetings, meeting)
+	}
+
+	return rows.Err()
+}
+
+// "?, ?, ?" for n parameters
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Runs a query selecting a single term_crn column
+func (s *Store) selectCRNs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	return selectCRNsWith(ctx, s.queryContext, query, args...)
+}
+
+func selectCRNsWith(ctx context.Context, queryFn queryFunc, query string, args ...interface{}) ([]string, error) {
+	rows, err := queryFn(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	crns := make([]string, 0)
+
+	for rows.Next() {
+		var term_crn string
+		if err := rows.Scan(&term_crn); err != nil {
+			return nil, err
+		}
+
+		crns = append(crns, term_crn)
+	}
+
+	return crns, rows.Err()
+}
+
 var QueryableKeys = map[string]string{
 	"term_crn":       "CRN",
 	"title":          "Title",
 	"subject_code":   "Subject",
 	"course_number":  "Number",
 	"subject-number": "Subject & Number",
+	"credits":        "Credits",
+	"term":           "Term",
 }
 
-func QueryCourse(key string, values ...string) ([]courseload.Course, error) {
+// The column compared against for keys without their own case in
+// QueryCourse. Only names from this map are ever placed into the SQL
+var queryColumns = map[string]string{
+	"term_crn":      "term_crn",
+	"course_number": "course_number",
+	"term":          "term",
+}
+
+// Courses whose key matches values. The matches are hydrated by
+// getCoursesByCRN, three queries per hydrateBatchSize courses rather than
+// three per course
+func (s *Store) QueryCourse(key string, values ...string) ([]courseload.Course, error) {
+	return s.QueryCourseContext(context.Background(), key, values...)
+}
+
+func (s *Store) QueryCourseContext(ctx context.Context, key string, values ...string) ([]courseload.Course, error) {
+	where, args, err := queryCourseWhere(key, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryCoursesWhere(ctx, where, args)
+}
+
+// How QueryCourseSubject compares subject codes
+type SubjectMatch int
+
+const (
+	// The whole subject code, as QueryCourse's subject_code key does.
+	// Values are resolved by SuggestSubject, so names work too
+	SubjectExact SubjectMatch = iota
+	// Subject codes starting with the value, so "CO" finds COMP and COMM
+	SubjectPrefix
+	// Subject codes containing the value anywhere
+	SubjectContains
+)
+
+var subjectMatchNames = map[string]SubjectMatch{
+	"exact":    SubjectExact,
+	"prefix":   SubjectPrefix,
+	"contains": SubjectContains,
+}
+
+// The SubjectMatch named by s, with "" meaning SubjectExact
+func ParseSubjectMatch(s string) (SubjectMatch, error) {
+	if s == "" {
+		return SubjectExact, nil
+	}
+
+	mode, ok := subjectMatchNames[strings.ToLower(s)]
+	if !ok {
+		return SubjectExact, fmt.Errorf("unknown subject match %q", s)
+	}
+
+	return mode, nil
+}
+
+// Courses whose subject code matches any of values under mode. In the
+// prefix and contains modes % and _ match themselves rather than acting
+// as wildcards
+func (s *Store) QueryCourseSubject(mode SubjectMatch, values ...string) ([]courseload.Course, error) {
+	return s.QueryCourseSubjectContext(context.Background(), mode, values...)
+}
+
+func (s *Store) QueryCourseSubjectContext(ctx context.Context, mode SubjectMatch, values ...string) ([]courseload.Course, error) {
+	where, args, err := subjectWhere(mode, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryCoursesWhere(ctx, where, args)
+}
+
+func (s *Store) queryCoursesWhere(ctx context.Context, where string, args []interface{}) ([]courseload.Course, error) {
+	crns, err := s.selectCRNs(ctx, "SELECT term_crn FROM courses WHERE archived_at IS NULL AND "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getCoursesByCRN(ctx, crns)
+}
+
+// The condition matching any of values against subject_code under mode
+func subjectWhere(mode SubjectMatch, values []string) (string, []interface{}, error) {
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("key subject_code needs a value")
+	}
+
+	args := make([]interface{}, len(values))
+
+	switch mode {
+	case SubjectExact:
+		// Every value is a subject, so one call can ask for several
+		for i, value := range values {
+			args[i], _ = SuggestSubject(value)
+		}
+
+		return fmt.Sprintf("subject_code IN (%s)", placeholders(len(args))), args, nil
+	case SubjectPrefix, SubjectContains:
+		conditions := make([]string, len(values))
+
+		for i, value := range values {
+			pattern := escapeLike(strings.TrimSpace(value)) + "%"
+			if mode == SubjectContains {
+				pattern = "%" + pattern
+			}
+
+			conditions[i] = `subject_code LIKE ? ESCAPE '\'`
+			args[i] = pattern
+		}
+
+		return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+	default:
+		return "", nil, fmt.Errorf("unknown subject match %d", mode)
+	}
+}
+
+// One page of QueryCourse's results ordered by CRN, along with the total
+// number of matching courses
+func (s *Store) QueryCoursePage(key string, limit, offset int, values ...string) ([]courseload.Course, int, error) {
+	if limit < 0 || offset < 0 {
+		return nil, 0, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	where, args, err := queryCourseWhere(key, values)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+
+	err = s.queryRowContext(context.Background(), "SELECT COUNT(*) FROM courses WHERE archived_at IS NULL AND "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE archived_at IS NULL AND "+where+" ORDER BY term_crn LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	courses, err := s.getCoursesByCRN(context.Background(), crns)
+	return courses, total, err
+}
+
+// The WHERE clause and its arguments selecting the courses matching key
+// and values
+func queryCourseWhere(key string, values []string) (string, []interface{}, error) {
 	if _, ok := QueryableKeys[key]; !ok {
-		return nil, fmt.Errorf("key %s is not queryable", key)
+		return "", nil, fmt.Errorf("key %s is not queryable", key)
 	}
 
-	var rows *sql.Rows
-	var err error
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("key %s needs a value", key)
+	}
+
+	if key == "subject-number" {
+		subject, _ := SuggestSubject(values[0])
+		values = append([]string{subject}, values[1:]...)
+	}
 
 	switch key {
+	case "subject_code":
+		return subjectWhere(SubjectExact, values)
 	case "title":
-		rows, err = QueuedQuery("SELECT term_crn FROM courses WHERE title LIKE ?", "%"+values[0]+"%")
+		return "title LIKE ?", []interface{}{"%" + values[0] + "%"}, nil
+	case "credits":
+		// Credits are stored as REAL, so 1.5 is matched within a tolerance
+		// rather than exactly
+		credits, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("credits %q is not a number", values[0])
+		}
+
+		return "ABS(credits - ?) < 0.001", []interface{}{credits}, nil
 	case "subject-number":
-		rows, err = QueuedQuery("SELECT term_crn FROM courses WHERE subject_code = ? AND course_number LIKE ?", values[0], "%"+values[1]+"%")
+		if len(values) < 2 {
+			return "", nil, fmt.Errorf("key %s needs a subject and a number", key)
+		}
+
+		return "subject_code = ? AND course_number LIKE ?", []interface{}{values[0], "%" + values[1] + "%"}, nil
 	default:
-		rows, err = QueuedQuery("SELECT term_crn FROM courses WHERE "+key+" = ?", values[0])
+		column, ok := queryColumns[key]
+		if !ok {
+			return "", nil, fmt.Errorf("key %s is not queryable", key)
+		}
+
+		return column + " = ?", []interface{}{values[0]}, nil
 	}
+}
+
+// Sections in a subject with at least one seat available, ordered by CRN.
+// Sections whose seats aren't known are left out
+func GetOpenCourses(subject string) ([]courseload.Course, error) {
+	return defaultStore.GetOpenCourses(subject)
+}
+
+func (s *Store) GetOpenCourses(subject string) ([]courseload.Course, error) {
+	subject, _ = SuggestSubject(subject)
 
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE subject_code = ? COLLATE NOCASE AND seats_total > 0 AND seats_available > 0 AND archived_at IS NULL ORDER BY term_crn;", subject)
 	if err != nil {
 		return nil, err
 	}
 
-	courses := make([]courseload.Course, 0)
+	return s.getCoursesByCRN(context.Background(), crns)
+}
 
-	for rows.Next() {
-		var term_crn string
-		err = rows.Scan(&term_crn)
-		if err != nil {
-			return nil, err
-		}
+// Columns UpdateCourseField may change, mapped to the table holding them
+var updatableFields = map[string]string{
+	"title":          "courses",
+	"subject_code":   "courses",
+	"course_number":  "courses",
+	"section_number": "courses",
+	"description":    "courses",
+	"days":           "meetings",
+	"building":       "meetings",
+	"room":           "meetings",
+	"time":           "meetings",
+}
 
-		course, err := GetCourse(term_crn)
-		if err != nil {
-			return nil, err
-		}
+// Replaces every occurrence of from with to in a single whitelisted column,
+// returning the number of rows changed. Used for data cleanup such as
+// normalizing building names
+func UpdateCourseField(field, from, to string) (int, error) {
+	return defaultStore.UpdateCourseField(field, from, to)
+}
 
-		courses = append(courses, *course)
+func (s *Store) UpdateCourseField(field, from, to string) (int, error) {
+	table, ok := updatableFields[field]
+	if !ok {
+		return 0, fmt.Errorf("field %s is not updatable", field)
 	}
 
-	return courses, nil
+	defer s.cache.clear()
+
+	transaction, err := s.beginContext(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	query, args := "UPDATE "+table+" SET "+field+" = ? WHERE "+field+" = ?;", []interface{}{to, from}
+	if table == "courses" {
+		query, args = "UPDATE courses SET "+field+" = ?, updated_at = ? WHERE "+field+" = ?;", []interface{}{to, time.Now().UTC(), from}
+	}
+
+	// table and field both come from updatableFields
+	result, err := transaction.Exec(query, args...)
+	if err != nil {
+		transaction.Rollback()
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		transaction.Rollback()
+		return 0, err
+	}
+
+	return int(affected), transaction.Commit()
+}
+
+// Every active course in a term, given as its YYYYTT code such as "202410"
+func GetCoursesByTerm(term string) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesByTerm(term)
+}
+
+func (s *Store) GetCoursesByTerm(term string) ([]courseload.Course, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil, fmt.Errorf("term must not be empty")
+	}
+
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE term = ? AND archived_at IS NULL ORDER BY term_crn;", term)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getCoursesByCRN(context.Background(), crns)
 }
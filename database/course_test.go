@@ -0,0 +1,937 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestInsertCourseRollsBack(t *testing.T) {
+	s := newTestStore(t)
+
+	// Stands in for any constraint a meeting row could break
+	_, err := s.db.Exec(`CREATE TRIGGER reject_meeting BEFORE INSERT ON meetings WHEN new.room = 'bad'
+BEGIN SELECT RAISE(ABORT, 'bad meeting'); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("MWF", "9:10am-10:00am"), testMeeting("R", "2:10pm-3:30pm"))
+	course.Data.Meetings[1].Room = "bad"
+
+	err = s.InsertCourse(course)
+	if err == nil {
+		t.Fatal("InsertCourse succeeded with a bad meeting")
+	}
+
+	if !strings.Contains(err.Error(), "R 2:10pm-3:30pm") || !strings.Contains(err.Error(), "bad meeting") {
+		t.Errorf("error %q doesn't name the meeting and its cause", err)
+	}
+
+	if _, err = s.GetCourse(course.CRN); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("GetCourse after a failed insert = %v, want ErrCourseNotFound", err)
+	}
+
+	for _, table := range []string{"courses", "course_instructors", "meetings"} {
+		var count int
+		if err = s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+
+		if count != 0 {
+			t.Errorf("%s has %d rows after a failed insert", table, count)
+		}
+	}
+}
+
+func TestUpdateCourseField(t *testing.T) {
+	useTestDatabase(t)
+
+	if err := InsertCourse(testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field, from, to string
+		changed         int
+		wantErr         bool
+	}{
+		{"building", "Kingsbury", "Kingsbury Hall", 1, false},
+		{"building", "Kingsbury", "Elsewhere", 0, false},
+		{"title", "Software Engineering", "Software Eng.", 1, false},
+		{"term_crn", "20241012345", "20241099999", 0, true},
+		{"password", "", "", 0, true},
+		{"title = title; DROP TABLE courses; --", "", "", 0, true},
+	}
+
+	for _, test := range tests {
+		changed, err := UpdateCourseField(test.field, test.from, test.to)
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("UpdateCourseField(%q) error = %v, want error %v", test.field, err, test.wantErr)
+		}
+
+		if changed != test.changed {
+			t.Errorf("UpdateCourseField(%q) changed %d rows, want %d", test.field, changed, test.changed)
+		}
+	}
+
+	course, err := GetCourse("20241012345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if course.Data.Title != "Software Eng." || course.Data.Meetings[0].Building != "Kingsbury Hall" {
+		t.Errorf("course after updates = %+v", course.Data)
+	}
+}
+
+func TestGetCourseSummary(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("MWF", "9:10am-10:00am"))
+	course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.Credits = 30, 4, 4
+
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once from the database and once from a cache holding the full course
+	for _, cached := range []bool{false, true} {
+		if cached {
+			if _, err := s.GetCourse(course.CRN); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		summary, err := s.GetCourseSummary(course.CRN)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := summary.Data
+		if data.Title != "Software Engineering" || data.Subject != "COMP" || data.Number != "405" || data.SectionNum != "01" ||
+			data.SeatsTotal != 30 || data.SeatsAvailable != 4 || data.Credits != 4 {
+			t.Errorf("cached %v: summary = %+v", cached, data)
+		}
+
+		if data.Instructors == nil || len(data.Instructors) != 0 || data.Meetings == nil || len(data.Meetings) != 0 {
+			t.Errorf("cached %v: summary children = %v, %v, want empty slices", cached, data.Instructors, data.Meetings)
+		}
+	}
+
+	full, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(full.Data.Instructors) != 1 || len(full.Data.Meetings) != 1 {
+		t.Errorf("GetCourse after GetCourseSummary lost children: %+v", full.Data)
+	}
+
+	if _, err = s.GetCourseSummary("20241099999"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("GetCourseSummary of a missing course = %v, want ErrCourseNotFound", err)
+	}
+}
+
+func TestCourseTimestamps(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering")
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inserted.CreatedAt == nil || inserted.UpdatedAt == nil || !inserted.CreatedAt.Equal(*inserted.UpdatedAt) || time.Since(*inserted.CreatedAt) > time.Minute {
+		t.Fatalf("inserted course timestamps = %v, %v", inserted.CreatedAt, inserted.UpdatedAt)
+	}
+
+	// Cached copies don't share their timestamps
+	*inserted.UpdatedAt = time.Time{}
+
+	created := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+	if _, err = s.db.Exec("UPDATE courses SET created_at = ?, updated_at = ?;", created, created); err != nil {
+		t.Fatal(err)
+	}
+
+	s.cache.clear()
+
+	course.Data.Title = "Software Engineering II"
+	if err = s.UpdateCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		load func() (*courseload.Course, error)
+	}{
+		{"GetCourse", func() (*courseload.Course, error) { return s.GetCourse(course.CRN) }},
+		{"GetCourse cached", func() (*courseload.Course, error) { return s.GetCourse(course.CRN) }},
+		{"GetCourseSummary", func() (*courseload.Course, error) { return s.GetCourseSummary(course.CRN) }},
+		{"GetCourses", func() (*courseload.Course, error) {
+			courses, err := s.GetCourses([]string{course.CRN})
+			if err != nil || len(courses) != 1 {
+				return nil, fmt.Errorf("got %d courses: %v", len(courses), err)
+			}
+			return &courses[0], nil
+		}},
+	}
+
+	for _, test := range tests {
+		updated, err := test.load()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if updated.CreatedAt == nil || !updated.CreatedAt.Equal(created) {
+			t.Errorf("%s: CreatedAt = %v, want %v", test.name, updated.CreatedAt, created)
+		}
+
+		if updated.UpdatedAt == nil || !updated.UpdatedAt.After(created) || time.Since(*updated.UpdatedAt) > time.Minute {
+			t.Errorf("%s: UpdatedAt = %v, want about now", test.name, updated.UpdatedAt)
+		}
+	}
+}
+
+func TestSoftDeletedCoursesLeftOut(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am")),
+		testCourse("20241000002", "COMP", "405", "Software Engineering"),
+		testCourse("20241000003", "COMP", "410", "Data Science", testMeeting("TR", "2:10pm-3:30pm")),
+	} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The soft deleted course is the most popular, so it would take the
+	// first place on the page
+	for _, email := range []string{"a@unh.edu", "b@unh.edu"} {
+		if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", email, status)
+		}
+
+		if err := SetUserClasses(email, []string{"20241000003", "20241000002"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SoftDeleteCourse("20241000003"); err != nil {
+		t.Fatal(err)
+	}
+
+	popular, err := GetCoursesInSubjectByPopularity("COMP", 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(popular) != 2 || popular[0].CRN != "20241000002" || popular[1].CRN != "20241000001" {
+		t.Errorf("GetCoursesInSubjectByPopularity = %v, want 20241000002 then 20241000001", popular)
+	}
+
+	density, err := MeetingDensity()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(density) != 3 || density["M"][9] != 1 || density["T"] != nil {
+		t.Errorf("MeetingDensity = %v, want only MWF at 9", density)
+	}
+
+	distribution, err := SubjectTimeDistribution("COMP")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(distribution) != 1 || distribution[9] != 1 {
+		t.Errorf("SubjectTimeDistribution = %v, want 9:1", distribution)
+	}
+
+	words, err := TitleWordFrequency("COMP", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, word := range words {
+		if word.Word == "science" || (word.Word == "data" && word.Count != 1) {
+			t.Errorf("TitleWordFrequency counted the soft deleted course: %v", words)
+		}
+	}
+
+	report, err := CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	} else if report.MissingUserClasses != nil {
+		t.Errorf("enrollments in a soft deleted course reported missing: %v", report.MissingUserClasses)
+	}
+}
+
+func TestApplyCourseUpdates(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Unchanged"),
+		testCourse("20241000002", "COMP", "405", "Old title"),
+		testCourse("20241000003", "COMP", "410", "Dropped"),
+		testCourse("20241000004", "COMP", "415", "Can't be dropped"),
+		testCourse("20241000005", "COMP", "420", "Dropped earlier"),
+	} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SoftDeleteCourse("20241000005"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stand in for a soft delete and an insert failing part way through
+	_, err := defaultStore.db.Exec(`CREATE TRIGGER keep_course BEFORE UPDATE OF archived_at ON courses
+WHEN new.term_crn = '20241000004' AND new.archived_at IS NOT NULL BEGIN SELECT RAISE(ABORT, 'kept'); END;
+CREATE TRIGGER reject_course BEFORE INSERT ON meetings WHEN new.room = 'bad' BEGIN SELECT RAISE(ABORT, 'bad meeting'); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := testCourse("20241000007", "COMP", "430", "Bad", testMeeting("MWF", "9:10am-10:00am"))
+	bad.Data.Meetings[0].Room = "bad"
+
+	stats := applyCourseUpdates([]courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Unchanged"),
+		testCourse("20241000002", "COMP", "405", "New title"),
+		testCourse("20241000005", "COMP", "420", "Dropped earlier"),
+		testCourse("20241000006", "COMP", "425", "Brand new"),
+		bad,
+	})
+
+	if want := (ScrapeRunStats{Added: 2, Updated: 1, Removed: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	for crn, active := range map[string]bool{
+		"20241000001": true,
+		"20241000002": true,
+		"20241000003": false,
+		"20241000004": true,
+		"20241000005": true,
+		"20241000006": true,
+		"20241000007": false,
+	} {
+		if _, err := GetCourse(crn); (err == nil) != active {
+			t.Errorf("course %s: GetCourse error = %v, want active %v", crn, err, active)
+		}
+	}
+
+	var meetings int
+	if err = defaultStore.db.QueryRow("SELECT COUNT(*) FROM meetings WHERE term_crn = '20241000007';").Scan(&meetings); err != nil || meetings != 0 {
+		t.Errorf("failed course left %d meetings behind: %v", meetings, err)
+	}
+}
+
+func TestGetOpenCourses(t *testing.T) {
+	useTestDatabase(t)
+
+	seats := map[string][2]int{
+		"20241000001": {30, 5},
+		"20241000002": {30, 0},
+		"20241000003": {0, 0},
+		"20241000004": {25, 25},
+		"20241000005": {20, 3},
+	}
+
+	for crn, seat := range seats {
+		course := testCourse(crn, "COMP", "400", "Data Structures")
+		course.Data.SeatsTotal, course.Data.SeatsAvailable = seat[0], seat[1]
+
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	other := testCourse("20241000006", "MATH", "425", "Calculus")
+	other.Data.SeatsTotal, other.Data.SeatsAvailable = 30, 10
+	if err := InsertCourse(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SoftDeleteCourse("20241000005"); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := GetOpenCourses("comp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var crns []string
+	for _, course := range open {
+		crns = append(crns, course.CRN)
+	}
+
+	// Full, unknown, soft deleted and other subject sections are left out
+	if fmt.Sprint(crns) != "[20241000001 20241000004]" {
+		t.Errorf("GetOpenCourses = %v, want [20241000001 20241000004]", crns)
+	}
+}
+
+func TestQueryCourseUnknownColumn(t *testing.T) {
+	// A key listed as queryable but missing from queryColumns, as happens
+	// when QueryableKeys gains an entry without a column
+	QueryableKeys["password"] = "Password"
+	t.Cleanup(func() { delete(QueryableKeys, "password") })
+
+	// The store is closed, so an error other than ErrDatabaseNotOpen shows
+	// the key was rejected before reaching the database
+	s := newTestStore(t)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"password", "password; DROP TABLE courses"} {
+		_, err := s.QueryCourse(key, "x")
+		if err == nil || !strings.Contains(err.Error(), "not queryable") || errors.Is(err, ErrDatabaseNotOpen) {
+			t.Errorf("QueryCourse(%q) error = %v, want not queryable", key, err)
+		}
+	}
+}
+
+// Hydrating the courses QueryCourse matches, one GetCourse per course as
+// before against the batched getCoursesByCRN. queries/op stays the same
+// for the batched path whatever the number of courses
+func BenchmarkQueryCourseHydration(b *testing.B) {
+	for _, n := range []int{10, 100} {
+		s, err := NewStore(b.TempDir() + "/db.sqlite")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		courses := make([]courseload.Course, n)
+		for i := range courses {
+			courses[i] = testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+		}
+
+		if err = s.BulkInsertCourses(courses); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("per course/%d", n), func(b *testing.B) {
+			before := Metrics().Queries
+
+			for i := 0; i < b.N; i++ {
+				s.ClearCache()
+
+				crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE subject_code = ?;", "COMP")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				for _, crn := range crns {
+					if _, err = s.GetCourse(crn); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+
+			b.ReportMetric(float64(Metrics().Queries-before)/float64(b.N), "queries/op")
+		})
+
+		b.Run(fmt.Sprintf("batched/%d", n), func(b *testing.B) {
+			before := Metrics().Queries
+
+			for i := 0; i < b.N; i++ {
+				found, err := s.QueryCourse("subject_code", "COMP")
+				if err != nil {
+					b.Fatal(err)
+				} else if len(found) != n {
+					b.Fatalf("QueryCourse found %d courses, want %d", len(found), n)
+				}
+			}
+
+			b.ReportMetric(float64(Metrics().Queries-before)/float64(b.N), "queries/op")
+		})
+
+		s.Close()
+	}
+}
+
+func TestQueryCourseCredits(t *testing.T) {
+	useTestDatabase(t)
+
+	credits := map[string]float64{"20241000001": 4, "20241000002": 1.5, "20241000003": 4, "20241000004": 0}
+
+	for crn, value := range credits {
+		course := testCourse(crn, "COMP", "400", "Course")
+		course.Data.Credits = value
+
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		value string
+		crns  []string
+	}{
+		{"4", []string{"20241000001", "20241000003"}},
+		{" 4.0 ", []string{"20241000001", "20241000003"}},
+		{"1.5", []string{"20241000002"}},
+		{"3", []string{}},
+	}
+
+	for _, test := range tests {
+		courses, err := QueryCourse("credits", test.value)
+		if err != nil {
+			t.Fatalf("%q: %v", test.value, err)
+		}
+
+		crns := make([]string, 0, len(courses))
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+
+			if course.Data.Credits != credits[course.CRN] {
+				t.Errorf("%q: course %s has %v credits", test.value, course.CRN, course.Data.Credits)
+			}
+		}
+
+		slices.Sort(crns)
+		if !slices.Equal(crns, test.crns) {
+			t.Errorf("%q: got %v, want %v", test.value, crns, test.crns)
+		}
+	}
+
+	if _, err := QueryCourse("credits", "four"); err == nil {
+		t.Error("QueryCourse with non-numeric credits succeeded")
+	}
+}
+
+func TestGetCoursesByTerm(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, crn := range []string{"20241000002", "20241000001", "20242000001", "20242000002"} {
+		if err := InsertCourse(testCourse(crn, "COMP", "400", "Data Structures")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SoftDeleteCourse("20242000002"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		term string
+		crns []string
+	}{
+		{"202410", []string{"20241000001", "20241000002"}},
+		{" 202420 ", []string{"20242000001"}},
+		{"202430", []string{}},
+		{"2024", []string{}},
+	}
+
+	for _, test := range tests {
+		courses, err := GetCoursesByTerm(test.term)
+		if err != nil {
+			t.Fatalf("%q: %v", test.term, err)
+		}
+
+		crns := make([]string, 0, len(courses))
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.crns) {
+			t.Errorf("GetCoursesByTerm(%q) = %v, want %v", test.term, crns, test.crns)
+		}
+	}
+
+	if courses, err := QueryCourse("term", "202420"); err != nil || len(courses) != 1 || courses[0].CRN != "20242000001" {
+		t.Errorf(`QueryCourse("term", "202420") = %v, %v, want 20242000001`, courses, err)
+	}
+
+	if _, err := GetCoursesByTerm(" "); err == nil {
+		t.Error("GetCoursesByTerm with an empty term succeeded")
+	}
+}
+
+func TestUpdateCourse(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"), testMeeting("R", "2:10pm-3:00pm"))
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	course.Data.Title = "Data Structures and Algorithms"
+	course.Data.Instructors = append(course.Data.Instructors, courseload.Instructor{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"})
+	course.Data.Meetings = course.Data.Meetings[:1]
+
+	if err := s.UpdateCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Data.Title != course.Data.Title {
+		t.Errorf("title = %q, want %q", stored.Data.Title, course.Data.Title)
+	}
+
+	if len(stored.Data.Instructors) != 2 || stored.Data.Instructors[1].LastName != "Roe" {
+		t.Errorf("instructors = %+v, want Doe and Roe", stored.Data.Instructors)
+	}
+
+	if len(stored.Data.Meetings) != 1 || stored.Data.Meetings[0].Days != "MWF" {
+		t.Errorf("meetings = %+v, want only MWF", stored.Data.Meetings)
+	}
+
+	for table, want := range map[string]int{"course_instructors": 2, "meetings": 1} {
+		var count int
+		if err = s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil || count != want {
+			t.Errorf("%s has %d rows, %v, want %d", table, count, err, want)
+		}
+	}
+
+	if err = s.UpdateCourse(testCourse("20241099999", "COMP", "400", "Missing")); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("updating a missing course = %v, want ErrCourseNotFound", err)
+	}
+
+	if count, _ := s.CountCourses(); count != 1 {
+		t.Errorf("got %d courses after updating a missing one, want 1", count)
+	}
+}
+
+func TestQueryCourseSeveralSubjects(t *testing.T) {
+	s := newTestStore(t)
+
+	for i, subject := range []string{"COMP", "MATH", "PHYS", "ENGL", "COMP"} {
+		if err := s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), subject, "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	courses, err := s.QueryCourse("subject_code", "COMP", "math", " PHYS ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, course := range courses {
+		counts[course.Data.Subject]++
+	}
+
+	if len(courses) != 4 || counts["COMP"] != 2 || counts["MATH"] != 1 || counts["PHYS"] != 1 {
+		t.Errorf("QueryCourse over three subjects gave %v, want two COMP, one MATH and one PHYS", counts)
+	}
+
+	if courses, err = s.QueryCourse("subject_code", "COMP"); err != nil || len(courses) != 2 {
+		t.Errorf("QueryCourse for one subject = %d courses, %v, want 2", len(courses), err)
+	}
+
+	if _, err = s.QueryCourse("subject_code"); err == nil {
+		t.Error("QueryCourse without a subject succeeded")
+	}
+}
+
+func TestCoursePages(t *testing.T) {
+	s := newTestStore(t)
+
+	var crns []string
+	for i := range 30 {
+		crn := fmt.Sprintf("202410%05d", i)
+		crns = append(crns, crn)
+
+		subject := "COMP"
+		if i%3 == 0 {
+			subject = "MATH"
+		}
+
+		if err := s.InsertCourse(testCourse(crn, subject, "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		limit, offset int
+		want          []string
+	}{
+		{10, 0, crns[:10]},
+		{10, 10, crns[10:20]},
+		{10, 25, crns[25:]},
+		{10, 30, nil},
+		{0, 0, nil},
+	} {
+		page, err := s.GetCourseCRNsPage(test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(page, test.want) {
+			t.Errorf("GetCourseCRNsPage(%d, %d) = %v, want %v", test.limit, test.offset, page, test.want)
+		}
+	}
+
+	courses, total, err := s.QueryCoursePage("subject_code", 4, 8, "MATH")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 10 {
+		t.Errorf("total = %d, want the 10 MATH courses", total)
+	}
+
+	if len(courses) != 2 || courses[0].CRN != crns[24] || courses[1].CRN != crns[27] {
+		t.Errorf("the last MATH page = %v, want %s and %s", courses, crns[24], crns[27])
+	}
+
+	for _, bounds := range [][2]int{{-1, 0}, {10, -1}} {
+		if _, err = s.GetCourseCRNsPage(bounds[0], bounds[1]); err == nil {
+			t.Errorf("GetCourseCRNsPage(%d, %d) succeeded", bounds[0], bounds[1])
+		}
+
+		if _, _, err = s.QueryCoursePage("subject_code", bounds[0], bounds[1], "MATH"); err == nil {
+			t.Errorf("QueryCoursePage(%d, %d) succeeded", bounds[0], bounds[1])
+		}
+	}
+}
+
+func TestGetCoursesKeepsOrder(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, crn := range []string{"20241000001", "20241000002", "20241000003"} {
+		if err := s.InsertCourse(testCourse(crn, "COMP", "400", "Course "+crn, testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	courses, err := s.GetCourses([]string{"20241000003", "bogus", "20241000001", "20241099999", "20241000002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var crns []string
+	for _, course := range courses {
+		crns = append(crns, course.CRN)
+
+		if course.Data.Title != "Course "+course.CRN || len(course.Data.Instructors) != 1 || len(course.Data.Meetings) != 1 {
+			t.Errorf("%s not fully hydrated: %+v", course.CRN, course.Data)
+		}
+	}
+
+	if want := []string{"20241000003", "20241000001", "20241000002"}; !slices.Equal(crns, want) {
+		t.Errorf("GetCourses = %v, want %v with the invalid CRNs skipped", crns, want)
+	}
+
+	if courses, err = s.GetCourses(nil); err != nil || len(courses) != 0 {
+		t.Errorf("GetCourses(nil) = %v, %v, want none", courses, err)
+	}
+}
+
+func TestInsertOrUpdateCourse(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("2024100000A", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))
+	if err := s.InsertOrUpdateCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	course.CRN = "2024100000a"
+	course.Data.Title = "Data Structures and Algorithms"
+	course.Data.Meetings = append(course.Data.Meetings, testMeeting("R", "2:10pm-3:00pm"))
+
+	for range 3 {
+		if err := s.InsertOrUpdateCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for table, want := range map[string]int{"courses": 1, "meetings": 2, "course_instructors": 1, "instructor_profiles": 1} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil || count != want {
+			t.Errorf("%s has %d rows, %v, want %d", table, count, err, want)
+		}
+	}
+
+	stored, err := s.GetCourse("2024100000A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.CRN != "2024100000A" || stored.Data.Title != course.Data.Title || len(stored.Data.Meetings) != 2 {
+		t.Errorf("GetCourse = %s %q with %d meetings, want the update under the original CRN", stored.CRN, stored.Data.Title, len(stored.Data.Meetings))
+	}
+}
+
+func TestCountCourses(t *testing.T) {
+	s := newTestStore(t)
+
+	if count, err := s.CountCourses(); err != nil || count != 0 {
+		t.Errorf("CountCourses on an empty database = %d, %v, want 0", count, err)
+	}
+
+	if counts, err := s.CountCoursesBySubject(); err != nil || len(counts) != 0 {
+		t.Errorf("CountCoursesBySubject on an empty database = %v, %v, want none", counts, err)
+	}
+
+	for i, subject := range []string{"COMP", "COMP", "COMP", "MATH", "MATH", "ENGL"} {
+		if err := s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), subject, "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Soft deleted courses aren't counted
+	if err := s.SoftDeleteCourse("20241000005"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := s.CountCourses(); err != nil || count != 5 {
+		t.Errorf("CountCourses = %d, %v, want 5", count, err)
+	}
+
+	counts, err := s.CountCoursesBySubject()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(counts) != 2 || counts["COMP"] != 3 || counts["MATH"] != 2 {
+		t.Errorf("CountCoursesBySubject = %v, want COMP 3 and MATH 2", counts)
+	}
+}
+
+func TestGetCourseCRNsByPrefix(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, crn := range []string{"20241000010", "20241000011", "20241000012", "20241000020", "20242000010", "2024%000001", "2024_000001"} {
+		if err := s.InsertCourse(testCourse(crn, "COMP", "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"2024100001", 10, []string{"20241000010", "20241000011", "20241000012"}},
+		{"2024100001", 2, []string{"20241000010", "20241000011"}},
+		{"202410", 10, []string{"20241000010", "20241000011", "20241000012", "20241000020"}},
+		{"2024%", 10, []string{"2024%000001"}},
+		{"2024_", 10, []string{"2024_000001"}},
+		{"3", 10, nil},
+	} {
+		crns, err := s.GetCourseCRNsByPrefix(test.prefix, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("GetCourseCRNsByPrefix(%q, %d) = %v, want %v", test.prefix, test.limit, crns, test.want)
+		}
+	}
+
+	if _, err := s.GetCourseCRNsByPrefix("2024", 0); err == nil {
+		t.Error("GetCourseCRNsByPrefix with a limit of 0 succeeded")
+	}
+}
+
+func TestQueryCourseSubject(t *testing.T) {
+	s := newTestStore(t)
+
+	for i, subject := range []string{"CO", "COMM", "COMP", "ECON", "MATH"} {
+		if err := s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), subject, "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		mode   string
+		values []string
+		want   []string
+	}{
+		{"", []string{"CO"}, []string{"CO"}},
+		{"exact", []string{"compsci"}, []string{"COMP"}},
+		{"exact", []string{"COM"}, nil},
+		{"prefix", []string{"co"}, []string{"CO", "COMM", "COMP"}},
+		{"Prefix", []string{"COMM", "MA"}, []string{"COMM", "MATH"}},
+		{"prefix", []string{"%"}, nil},
+		{"contains", []string{"CO"}, []string{"CO", "COMM", "COMP", "ECON"}},
+	} {
+		mode, err := ParseSubjectMatch(test.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		courses, err := s.QueryCourseSubject(mode, test.values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var subjects []string
+		for _, course := range courses {
+			subjects = append(subjects, course.Data.Subject)
+		}
+
+		slices.Sort(subjects)
+		if !slices.Equal(subjects, test.want) {
+			t.Errorf("%q match of %v = %v, want %v", test.mode, test.values, subjects, test.want)
+		}
+	}
+
+	if _, err := ParseSubjectMatch("fuzzy"); err == nil {
+		t.Error("ParseSubjectMatch accepted an unknown mode")
+	}
+
+	if _, err := s.QueryCourseSubject(SubjectPrefix); err == nil {
+		t.Error("QueryCourseSubject without a value succeeded")
+	}
+}
+
+func TestGetRandomCourse(t *testing.T) {
+	s := newTestStore(t)
+
+	if course, err := s.GetRandomCourse(); !errors.Is(err, ErrCourseNotFound) || course != nil {
+		t.Errorf("GetRandomCourse on an empty database = %v, %v, want ErrCourseNotFound", course, err)
+	}
+
+	crns := []string{"20241000001", "20241000002", "20241000003"}
+	for _, crn := range crns {
+		if err := s.InsertCourse(testCourse(crn, "COMP", "400", "Course "+crn, testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.InsertCourse(testCourse("20241000004", "COMP", "400", "Deleted")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SoftDeleteCourse("20241000004"); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+
+	for range 100 {
+		course, err := s.GetRandomCourse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Contains(crns, course.CRN) || course.Data.Title != "Course "+course.CRN || len(course.Data.Meetings) != 1 {
+			t.Fatalf("GetRandomCourse = %+v, want one of %v fully loaded", course, crns)
+		}
+
+		seen[course.CRN] = true
+	}
+
+	if len(seen) != len(crns) {
+		t.Errorf("100 draws only picked %v", seen)
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Compares a fresh scrape against the active courses by CRN without
+// changing anything. added are CRNs only in fresh, removed are CRNs only in
+// the database and changed are CRNs in both whose data differs, each
+// sorted. The added and changed courses are the ones InsertOrUpdateCourse
+// needs to be called with
+func DiffCourses(fresh []courseload.Course) (added, removed, changed []string, err error) {
+	return defaultStore.DiffCourses(fresh)
+}
+
+func (s *Store) DiffCourses(fresh []courseload.Course) (added, removed, changed []string, err error) {
+	current, err := coursesByKey(s.queryContext, "SELECT term_crn FROM courses WHERE archived_at IS NULL;")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	added, removed, changed = diffCourses(current, fresh)
+
+	return added, removed, changed, nil
+}
+
+// The courses selected by query, a query for term_crn, keyed by cacheKey.
+// Every statement is run by queryFn
+func coursesByKey(queryFn queryFunc, query string, args ...interface{}) (map[string]courseload.Course, error) {
+	crns, err := selectCRNsWith(context.Background(), queryFn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := loadCourses(context.Background(), queryFn, crns)
+	if err != nil {
+		return nil, err
+	}
+
+	courses := make(map[string]courseload.Course, len(loaded))
+
+	for _, course := range loaded {
+		courses[cacheKey(course.CRN)] = course
+	}
+
+	return courses, nil
+}
+
+// Sorts fresh against current, as DiffCourses describes
+func diffCourses(current map[string]courseload.Course, fresh []courseload.Course) (added, removed, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	seen := make(map[string]bool, len(fresh))
+
+	for _, course := range fresh {
+		key := cacheKey(course.CRN)
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		if existing, ok := current[key]; !ok {
+			added = append(added, course.CRN)
+		} else if !sameCourseData(existing.Data, course.Data) {
+			changed = append(changed, course.CRN)
+		}
+	}
+
+	for key, course := range current {
+		if !seen[key] {
+			removed = append(removed, course.CRN)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+
+	return added, removed, changed
+}
+
+// Whether stored and fresh describe the same course. Meeting IDs only
+// exist in the database and are ignored. Instructors are compared by name
+// and email, since their office is shared by every course they teach, and
+// without regard to case since the feed doesn't keep it consistent.
+// Emails are normalized as they would be stored, so a malformed one
+// matches the empty email stored in its place
+func sameCourseData(stored, fresh courseload.CourseData) bool {
+	if stored.Title != fresh.Title || stored.Subject != fresh.Subject || stored.Number != fresh.Number ||
+		stored.SectionNum != fresh.SectionNum || stored.Description != fresh.Description ||
+		stored.SeatsTotal != fresh.SeatsTotal || stored.SeatsAvailable != fresh.SeatsAvailable ||
+		stored.WaitlistCount != fresh.WaitlistCount || stored.Credits != fresh.Credits {
+		return false
+	}
+
+	if !slices.EqualFunc(stored.Meetings, fresh.Meetings, func(a, b courseload.Meeting) bool {
+		return a.Days == b.Days && a.Building == b.Building && a.Room == b.Room && a.Time == b.Time
+	}) {
+		return false
+	}
+
+	return slices.EqualFunc(stored.Instructors, fresh.Instructors, func(a, b courseload.Instructor) bool {
+		email, err := normalizeEmail(b.Email)
+		if err != nil {
+			email = ""
+		}
+
+		return strings.EqualFold(a.LastName, b.LastName) && strings.EqualFold(a.FirstName, b.FirstName) && strings.EqualFold(a.Email, email)
+	})
+}
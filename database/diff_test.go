@@ -0,0 +1,64 @@
+package database
+
+import (
+	"slices"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestSameCourseDataInstructors(t *testing.T) {
+	stored := testCourse("20241012345", "COMP", "405", "Software Engineering").Data
+	stored.Instructors = []courseload.Instructor{{LastName: "McDonald", FirstName: "Jane", Email: "Jane.Doe@unh.edu"}}
+
+	tests := []struct {
+		name       string
+		instructor courseload.Instructor
+		same       bool
+	}{
+		{"identical", courseload.Instructor{LastName: "McDonald", FirstName: "Jane", Email: "Jane.Doe@unh.edu"}, true},
+		{"name case", courseload.Instructor{LastName: "MCDONALD", FirstName: "jane", Email: "Jane.Doe@unh.edu"}, true},
+		{"email domain case and spaces", courseload.Instructor{LastName: "McDonald", FirstName: "Jane", Email: " Jane.Doe@UNH.EDU "}, true},
+		{"email local part case", courseload.Instructor{LastName: "McDonald", FirstName: "Jane", Email: "jane.doe@unh.edu"}, true},
+		{"other name", courseload.Instructor{LastName: "MacDonald", FirstName: "Jane", Email: "Jane.Doe@unh.edu"}, false},
+		{"other email", courseload.Instructor{LastName: "McDonald", FirstName: "Jane", Email: "jdoe@unh.edu"}, false},
+	}
+
+	for _, test := range tests {
+		fresh := stored
+		fresh.Instructors = []courseload.Instructor{test.instructor}
+
+		if same := sameCourseData(stored, fresh); same != test.same {
+			t.Errorf("%s: sameCourseData = %v, want %v", test.name, same, test.same)
+		}
+	}
+
+	// A malformed email is stored empty, so it isn't a change every scrape
+	stored.Instructors[0].Email = ""
+	fresh := stored
+	fresh.Instructors = []courseload.Instructor{{LastName: "McDonald", FirstName: "Jane", Email: "not an email"}}
+
+	if !sameCourseData(stored, fresh) {
+		t.Errorf("malformed email compared unequal to the empty one stored for it")
+	}
+}
+
+func TestDiffCoursesIgnoresInstructorCase(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241012345", "COMP", "405", "Software Engineering")); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := testCourse("20241012345", "COMP", "405", "Software Engineering")
+	fresh.Data.Instructors[0].LastName, fresh.Data.Instructors[0].Email = "DOE", "jane.doe@UNH.edu"
+
+	added, removed, changed, err := s.DiffCourses([]courseload.Course{fresh})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(added) != 0 || len(removed) != 0 || !slices.Equal(changed, []string{}) {
+		t.Errorf("DiffCourses = %v, %v, %v, want nothing", added, removed, changed)
+	}
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// Inserts the courses in a JSON array, as written by ExportCoursesJSON,
+// one at a time as they are decoded. Either every course is inserted or,
+// on the first bad or conflicting course, none are
+func ImportCoursesJSON(r io.Reader) (int, error) {
+	return defaultStore.ImportCoursesJSON(r)
+}
+
+func (s *Store) ImportCoursesJSON(r io.Reader) (int, error) {
+	defer s.cache.clear()
+
+	decoder := json.NewDecoder(r)
+
+	if token, err := decoder.Token(); err != nil {
+		return 0, fmt.Errorf("reading courses: %w", err)
+	} else if token != json.Delim('[') {
+		return 0, fmt.Errorf("reading courses: expected an array")
+	}
+
+	transaction, err := s.beginContext(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for decoder.More() {
+		var course courseload.Course
+
+		if err = decoder.Decode(&course); err != nil {
+			transaction.Rollback()
+			return 0, fmt.Errorf("reading course %d: %w", count+1, err)
+		}
+
+		if err = s.insertCourseTx(transaction, course); err != nil {
+			transaction.Rollback()
+			return 0, err
+		}
+
+		count++
+	}
+
+	if _, err = decoder.Token(); err != nil {
+		transaction.Rollback()
+		return 0, fmt.Errorf("reading courses: %w", err)
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return 0, err
+	}
+
+	util.Log.Status(fmt.Sprintf("Imported %d courses", count))
+
+	return count, nil
+}
+
+// Writes every course, ordered by CRN, as a JSON array. Courses are loaded
+// hydrateBatchSize at a time rather than all at once
+func ExportCoursesJSON(w io.Writer) error {
+	return defaultStore.ExportCoursesJSON(w)
+}
+
+func (s *Store) ExportCoursesJSON(w io.Writer) error {
+	return s.exportCourses(w, func(course courseload.Course) interface{} {
+		return course
+	})
+}
+
+// What each field ExportCourseFieldsJSON accepts writes, named after the
+// courses table's columns
+var exportFields = map[string]func(course courseload.Course) interface{}{
+	"term_crn":        func(course courseload.Course) interface{} { return course.CRN },
+	"title":           func(course courseload.Course) interface{} { return course.Data.Title },
+	"subject_code":    func(course courseload.Course) interface{} { return course.Data.Subject },
+	"course_number":   func(course courseload.Course) interface{} { return course.Data.Number },
+	"section_number":  func(course courseload.Course) interface{} { return course.Data.SectionNum },
+	"description":     func(course courseload.Course) interface{} { return course.Data.Description },
+	"seats_total":     func(course courseload.Course) interface{} { return course.Data.SeatsTotal },
+	"seats_available": func(course courseload.Course) interface{} { return course.Data.SeatsAvailable },
+	"waitlist_count":  func(course courseload.Course) interface{} { return course.Data.WaitlistCount },
+	"credits":         func(course courseload.Course) interface{} { return course.Data.Credits },
+	"instructors":     func(course courseload.Course) interface{} { return course.Data.Instructors },
+	"meetings":        func(course courseload.Course) interface{} { return course.Data.Meetings },
+}
+
+// Like ExportCoursesJSON but each course is a flat object holding only
+// fields, such as term_crn, title and meetings. An unknown field is an
+// error before anything is written
+func ExportCourseFieldsJSON(w io.Writer, fields []string) error {
+	return defaultStore.ExportCourseFieldsJSON(w, fields)
+}
+
+func (s *Store) ExportCourseFieldsJSON(w io.Writer, fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no export fields given")
+	}
+
+	for _, field := range fields {
+		if _, ok := exportFields[field]; !ok {
+			return fmt.Errorf("unknown export field %q", field)
+		}
+	}
+
+	return s.exportCourses(w, func(course courseload.Course) interface{} {
+		values := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			values[field] = exportFields[field](course)
+		}
+
+		return values
+	})
+}
+
+// Writes value of every course, ordered by CRN, as a JSON array. w is
+// flushed after each batch if it can be
+func (s *Store) exportCourses(w io.Writer, value func(course courseload.Course) interface{}) error {
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses ORDER BY term_crn;")
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	written := 0
+
+	for start := 0; start < len(crns); start += hydrateBatchSize {
+		courses, err := s.getCoursesByCRN(context.Background(), crns[start:min(start+hydrateBatchSize, len(crns))])
+		if err != nil {
+			return err
+		}
+
+		for _, course := range courses {
+			if written > 0 {
+				if _, err = io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+
+			if err = encoder.Encode(value(course)); err != nil {
+				return err
+			}
+
+			written++
+		}
+
+		// A compressing or buffering writer sends each batch on rather
+		// than holding the whole export
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			if err = flusher.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+// ExportCoursesJSON compressed with gzip, flushed after every batch of
+// courses. The output is a complete gzip stream, so an HTTP handler can
+// write it straight to the response after setting Content-Encoding: gzip
+// and Content-Type: application/json
+func ExportCoursesGzip(w io.Writer) error {
+	return defaultStore.ExportCoursesGzip(w)
+}
+
+func (s *Store) ExportCoursesGzip(w io.Writer) error {
+	compressor := gzip.NewWriter(w)
+
+	if err := s.ExportCoursesJSON(compressor); err != nil {
+		compressor.Close()
+		return err
+	}
+
+	return compressor.Close()
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestExportCourseFieldsJSON(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241012346", "MATH", "425", "Calculus")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertCourse(testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("TR", "9:30am-10:50am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := s.ExportCourseFieldsJSON(&out, []string{"term_crn", "title", "meetings"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("export isn't JSON: %v\n%s", err, out.String())
+	}
+
+	want := []map[string]interface{}{
+		{
+			"term_crn": "20241012345",
+			"title":    "Software Engineering",
+			"meetings": []interface{}{map[string]interface{}{"ID": float64(1), "DAYS": "TR", "BUILDING": "Kingsbury", "ROOM": "N101", "TIME": "9:30am-10:50am"}},
+		},
+		{"term_crn": "20241012346", "title": "Calculus", "meetings": []interface{}{}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("export = %v, want %v", got, want)
+	}
+}
+
+func TestExportCourseFieldsJSONRejects(t *testing.T) {
+	s := newTestStore(t)
+
+	tests := []struct {
+		name   string
+		fields []string
+		errMsg string
+	}{
+		{"unknown", []string{"term_crn", "professor"}, `unknown export field "professor"`},
+		{"wrong case", []string{"TITLE"}, `unknown export field "TITLE"`},
+		{"none", nil, "no export fields given"},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+
+		err := s.ExportCourseFieldsJSON(&out, test.fields)
+		if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.errMsg)
+		}
+
+		if out.Len() != 0 {
+			t.Errorf("%s: wrote %q before failing", test.name, out.String())
+		}
+	}
+}
+
+func TestExportCoursesGzip(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("TR", "9:30am-10:50am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var plain, compressed bytes.Buffer
+
+	if err := s.ExportCoursesJSON(&plain); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ExportCoursesGzip(&compressed); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, plain.Bytes()) {
+		t.Errorf("decompressed export = %s, want %s", decompressed, plain.Bytes())
+	}
+}
+
+func TestInstructorOfficeRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering")
+	course.Data.Instructors = []courseload.Instructor{
+		{LastName: "Doe", FirstName: "Jane", Email: "jane.doe@unh.edu", Office: "Kingsbury N215", OfficeHours: "MW 1:00pm-2:00pm"},
+		{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"},
+	}
+
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(stored.Data.Instructors, course.Data.Instructors) {
+		t.Errorf("stored instructors = %+v, want %+v", stored.Data.Instructors, course.Data.Instructors)
+	}
+
+	var dump bytes.Buffer
+	if err = s.ExportCoursesJSON(&dump); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestStore(t)
+	if _, err = other.ImportCoursesJSON(&dump); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := other.GetCourse(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(imported.Data.Instructors, course.Data.Instructors) {
+		t.Errorf("imported instructors = %+v, want %+v", imported.Data.Instructors, course.Data.Instructors)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+
+	// More than hydrateBatchSize, so the export spans several batches
+	courses := make([]courseload.Course, hydrateBatchSize+20)
+	for i := range courses {
+		course := testCourse(fmt.Sprintf("202410%05d", i), "COMP", fmt.Sprint(400+i%100), fmt.Sprintf("Course %d", i), testMeeting("MWF", "9:10am-10:00am"))
+		course.Data.SeatsTotal, course.Data.SeatsAvailable, course.Data.WaitlistCount, course.Data.Credits = 30, i%30, i%5, 4
+
+		if i%2 == 0 {
+			course.Data.Meetings = append(course.Data.Meetings, testMeeting("R", "2:10pm-3:00pm"))
+			course.Data.Instructors = append(course.Data.Instructors, courseload.Instructor{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"})
+		}
+
+		courses[i] = course
+	}
+
+	if err := s.BulkInsertCourses(courses); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump bytes.Buffer
+	if err := s.ExportCoursesJSON(&dump); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, course := range courses {
+		if err := s.DeleteCourse(course.CRN); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count, _ := s.CountCourses(); count != 0 {
+		t.Fatalf("got %d courses after wiping, want 0", count)
+	}
+
+	imported, err := s.ImportCoursesJSON(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported != len(courses) {
+		t.Errorf("imported %d courses, want %d", imported, len(courses))
+	}
+
+	for _, course := range courses {
+		stored, err := s.GetCourse(course.CRN)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Meetings are given new IDs when they are inserted again
+		for i := range stored.Data.Meetings {
+			stored.Data.Meetings[i].ID = 0
+		}
+
+		if !reflect.DeepEqual(stored.Data, course.Data) {
+			t.Fatalf("%s after the round trip = %+v, want %+v", course.CRN, stored.Data, course.Data)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Conditions a course has to meet, all of them at once. Conditions left at
+// their zero value are ignored, so the empty filter matches every course
+type CourseFilter struct {
+	// A subject code or name, resolved as SuggestSubject does
+	Subject string
+
+	// The start of the course number, such as "4" for 400 level courses.
+	// This and TitleContains are matched literally, so % and _ are not
+	// wildcards
+	NumberPrefix string
+
+	TitleContains string
+	MinCredits    float64
+
+	// Only sections with at least one seat available, as GetOpenCourses
+	// finds them
+	OpenOnly bool
+
+	// A YYYYTT term code such as "202410"
+	Term string
+
+	Sort SortBy
+}
+
+// The order filtered courses come back in
+type SortBy int
+
+const (
+	// Subject, then course number, both ascending
+	SortBySubjectNumber SortBy = iota
+	SortByNumber
+	SortByTitle
+)
+
+// The ORDER BY for each SortBy. Ties fall back to the CRN so the order is
+// always the same
+var sortOrders = map[SortBy]string{
+	SortBySubjectNumber: "subject_code, course_number, section_number, term_crn",
+	SortByNumber:        "course_number, subject_code, section_number, term_crn",
+	SortByTitle:         "title COLLATE NOCASE, subject_code, course_number, term_crn",
+}
+
+// The WHERE clause and its arguments for f. Soft deleted courses are always
+// left out
+func (f CourseFilter) where() (string, []interface{}) {
+	conditions := []string{"archived_at IS NULL"}
+	var args []interface{}
+
+	if subject := strings.TrimSpace(f.Subject); subject != "" {
+		subject, _ = SuggestSubject(subject)
+		conditions = append(conditions, "subject_code = ? COLLATE NOCASE")
+		args = append(args, subject)
+	}
+
+	if prefix := strings.TrimSpace(f.NumberPrefix); prefix != "" {
+		conditions = append(conditions, `course_number LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(prefix)+"%")
+	}
+
+	if title := strings.TrimSpace(f.TitleContains); title != "" {
+		conditions = append(conditions, `title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(title)+"%")
+	}
+
+	if f.MinCredits > 0 {
+		conditions = append(conditions, "credits >= ?")
+		args = append(args, f.MinCredits)
+	}
+
+	if f.OpenOnly {
+		conditions = append(conditions, "seats_total > 0 AND seats_available > 0")
+	}
+
+	if term := strings.TrimSpace(f.Term); term != "" {
+		conditions = append(conditions, "term = ?")
+		args = append(args, term)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Courses matching every condition set in f, ordered by f.Sort
+func QueryCoursesFiltered(f CourseFilter) ([]courseload.Course, error) {
+	return defaultStore.QueryCoursesFiltered(f)
+}
+
+func (s *Store) QueryCoursesFiltered(f CourseFilter) ([]courseload.Course, error) {
+	order, ok := sortOrders[f.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %d", f.Sort)
+	}
+
+	where, args := f.where()
+
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses"+where+" ORDER BY "+order+";", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
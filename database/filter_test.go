@@ -0,0 +1,119 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueryCoursesFiltered(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []struct {
+		crn, subject, number, title string
+		credits                     float64
+		total, available            int
+	}{
+		{"20241000001", "COMP", "400", "Data Structures", 4, 30, 5},
+		{"20241000002", "COMP", "405", "Software Engineering", 4, 30, 0},
+		{"20242000003", "COMP", "510", "100% Design", 3, 0, 0},
+		{"20241000004", "MATH", "425", "Calculus_I", 4, 20, 2},
+		{"20241000005", "MATH", "426", "Calculus I", 4, 0, 0},
+		{"20241000006", "MATH", "418", "Deleted", 4, 20, 2},
+	} {
+		stored := testCourse(course.crn, course.subject, course.number, course.title)
+		stored.Data.Credits, stored.Data.SeatsTotal, stored.Data.SeatsAvailable = course.credits, course.total, course.available
+
+		if err := InsertCourse(stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SoftDeleteCourse("20241000006"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter CourseFilter
+		crns   []string
+	}{
+		{"empty", CourseFilter{}, []string{"20241000001", "20241000002", "20242000003", "20241000004", "20241000005"}},
+		{"subject and number prefix", CourseFilter{Subject: "compsci", NumberPrefix: "4"}, []string{"20241000001", "20241000002"}},
+		{"open only", CourseFilter{Subject: "COMP", NumberPrefix: "4", OpenOnly: true}, []string{"20241000001"}},
+		{"term", CourseFilter{Term: "202420"}, []string{"20242000003"}},
+		{"credits and subject", CourseFilter{Subject: "math", MinCredits: 4}, []string{"20241000004", "20241000005"}},
+		{"title", CourseFilter{TitleContains: "calculus"}, []string{"20241000004", "20241000005"}},
+		{"literal percent", CourseFilter{TitleContains: "100%"}, []string{"20242000003"}},
+		{"literal underscore", CourseFilter{TitleContains: "Calculus_I"}, []string{"20241000004"}},
+		{"percent number prefix", CourseFilter{NumberPrefix: "%"}, []string{}},
+		{"underscore number prefix", CourseFilter{NumberPrefix: "4_"}, []string{}},
+		{"backslash", CourseFilter{TitleContains: `\`}, []string{}},
+		{"nothing matches all", CourseFilter{Subject: "MATH", Term: "202420"}, []string{}},
+	}
+
+	for _, test := range tests {
+		courses, err := QueryCoursesFiltered(test.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		crns := make([]string, 0, len(courses))
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.crns) {
+			t.Errorf("%s: got %v, want %v", test.name, crns, test.crns)
+		}
+	}
+}
+
+func TestQueryCoursesFilteredSort(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, course := range []struct {
+		crn, subject, number, section, title string
+	}{
+		{"20241000001", "MATH", "400", "01", "algebra"},
+		{"20241000002", "COMP", "425", "01", "Zeta"},
+		{"20241000003", "COMP", "400", "02", "beta"},
+		{"20241000004", "COMP", "400", "01", "Beta"},
+		{"20241000005", "ARTS", "500", "01", "Alpha"},
+	} {
+		stored := testCourse(course.crn, course.subject, course.number, course.title)
+		stored.Data.SectionNum = course.section
+
+		if err := s.InsertCourse(stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sort SortBy
+		crns []string
+	}{
+		{SortBySubjectNumber, []string{"20241000005", "20241000004", "20241000003", "20241000002", "20241000001"}},
+		{SortByNumber, []string{"20241000004", "20241000003", "20241000001", "20241000002", "20241000005"}},
+		{SortByTitle, []string{"20241000001", "20241000005", "20241000003", "20241000004", "20241000002"}},
+	}
+
+	for _, test := range tests {
+		courses, err := s.QueryCoursesFiltered(CourseFilter{Sort: test.sort})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crns := make([]string, 0, len(courses))
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.crns) {
+			t.Errorf("sort %d: got %v, want %v", test.sort, crns, test.crns)
+		}
+	}
+
+	if _, err := s.QueryCoursesFiltered(CourseFilter{Sort: SortByTitle + 1}); err == nil {
+		t.Error("QueryCoursesFiltered with an unknown sort order succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// One meeting of a course placed on a weekly grid. Start and End are on
+// the zero date, as ParseMeetingTime returns them
+type TimeBlock struct {
+	CRN, Title     string
+	Start, End     time.Time
+	Building, Room string
+}
+
+// A user's schedule laid out by weekday
+type WeeklyGrid struct {
+	// Each day's blocks ordered by start time. Overlapping blocks are all
+	// kept, DetectConflicts being what reports them
+	Days map[time.Weekday][]TimeBlock
+
+	// Meetings without a usable day or time, such as TBA ones, with zero
+	// Start and End
+	Unscheduled []TimeBlock
+}
+
+func BuildWeeklyGrid(email string) (WeeklyGrid, error) {
+	grid := WeeklyGrid{
+		Days:        make(map[time.Weekday][]TimeBlock),
+		Unscheduled: make([]TimeBlock, 0),
+	}
+
+	classes, err := GetUserClasses(email)
+	if err != nil {
+		return grid, err
+	}
+
+	courses, err := defaultStore.getCoursesByCRN(context.Background(), classes)
+	if err != nil {
+		return grid, err
+	}
+
+	for _, course := range courses {
+		for _, meeting := range course.Data.Meetings {
+			block := TimeBlock{
+				CRN:      course.CRN,
+				Title:    course.Data.Title,
+				Building: meeting.Building,
+				Room:     meeting.Room,
+			}
+
+			days, daysErr := courseload.ParseWeekdays(meeting.Days)
+			start, end, timeErr := courseload.ParseMeetingTime(meeting.Time)
+
+			if daysErr != nil || timeErr != nil || days == 0 {
+				grid.Unscheduled = append(grid.Unscheduled, block)
+				continue
+			}
+
+			block.Start, block.End = start, end
+
+			for _, day := range days.Days() {
+				grid.Days[day] = append(grid.Days[day], block)
+			}
+		}
+	}
+
+	for _, blocks := range grid.Days {
+		sort.Slice(blocks, func(i, j int) bool {
+			if !blocks[i].Start.Equal(blocks[j].Start) {
+				return blocks[i].Start.Before(blocks[j].Start)
+			}
+
+			if !blocks[i].End.Equal(blocks[j].End) {
+				return blocks[i].End.Before(blocks[j].End)
+			}
+
+			return blocks[i].CRN < blocks[j].CRN
+		})
+	}
+
+	return grid, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestBuildWeeklyGrid(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "10:10am-11:00am"), testMeeting("R", "2:10pm-3:00pm")),
+		testCourse("20241000002", "MATH", "425", "Calculus", testMeeting("MWF", "9:10am-10:00am")),
+		testCourse("20241000003", "COMP", "405", "Overlapping", testMeeting("M", "10:30am-11:30am")),
+		testCourse("20241000004", "COMP", "795", "Independent Study", testMeeting("TBA", "TBA")),
+	} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	if err := SetUserClasses(email, []string{"20241000001", "20241000004", "20241000003", "20241000002"}); err != nil {
+		t.Fatal(err)
+	}
+
+	grid, err := BuildWeeklyGrid(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	describe := func(blocks []TimeBlock) []string {
+		described := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			described = append(described, fmt.Sprintf("%s %s-%s", block.CRN, block.Start.Format("15:04"), block.End.Format("15:04")))
+		}
+		return described
+	}
+
+	want := map[time.Weekday][]string{
+		time.Monday:    {"20241000002 09:10-10:00", "20241000001 10:10-11:00", "20241000003 10:30-11:30"},
+		time.Wednesday: {"20241000002 09:10-10:00", "20241000001 10:10-11:00"},
+		time.Thursday:  {"20241000001 14:10-15:00"},
+		time.Friday:    {"20241000002 09:10-10:00", "20241000001 10:10-11:00"},
+	}
+
+	if len(grid.Days) != len(want) {
+		t.Errorf("grid has %d days, want %d", len(grid.Days), len(want))
+	}
+
+	for day, blocks := range want {
+		if got := describe(grid.Days[day]); !slices.Equal(got, blocks) {
+			t.Errorf("%s = %v, want %v", day, got, blocks)
+		}
+	}
+
+	if block := grid.Days[time.Thursday][0]; block.Title != "Data Structures" || block.Building != "Kingsbury" || block.Room != "N101" {
+		t.Errorf("Thursday block = %+v, want the course's title and room", block)
+	}
+
+	if len(grid.Unscheduled) != 1 || grid.Unscheduled[0].CRN != "20241000004" || !grid.Unscheduled[0].Start.IsZero() {
+		t.Errorf("Unscheduled = %+v, want only the TBA meeting", grid.Unscheduled)
+	}
+
+	if _, err = BuildWeeklyGrid("nobody@unh.edu"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("BuildWeeklyGrid for a missing user = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// The distinct instructors sharing at least one course with the instructor
+// identified by email
+func GetCoInstructors(email string) ([]courseload.Instructor, error) {
+	return defaultStore.GetCoInstructors(email)
+}
+
+func (s *Store) GetCoInstructors(email string) ([]courseload.Instructor, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email must not be empty")
+	}
+
+	rows, err := s.queryContext(context.Background(), SELECT_CO_INSTRUCTORS_STATEMENT, email)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	instructors := make([]courseload.Instructor, 0)
+
+	for rows.Next() {
+		var instructor courseload.Instructor
+		err = rows.Scan(&instructor.LastName, &instructor.FirstName, &instructor.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		instructors = append(instructors, instructor)
+	}
+
+	return instructors, rows.Err()
+}
+
+// Every course taught by the named instructor, matching both names without
+// regard to case. An empty first name matches on the last name alone
+func GetCoursesByInstructor(lastName, firstName string) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesByInstructor(lastName, firstName)
+}
+
+func (s *Store) GetCoursesByInstructor(lastName, firstName string) ([]courseload.Course, error) {
+	if lastName == "" {
+		return nil, fmt.Errorf("last name must not be empty")
+	}
+
+	crns, err := s.selectCRNs(context.Background(), SELECT_COURSES_BY_INSTRUCTOR_STATEMENT, lastName, firstName, firstName)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
+
+// Every instructor teaching at least one course, sorted by name
+func ListInstructors() ([]courseload.Instructor, error) {
+	return defaultStore.ListInstructors()
+}
+
+func (s *Store) ListInstructors() ([]courseload.Instructor, error) {
+	rows, err := s.queryContext(context.Background(), SELECT_LIST_INSTRUCTORS_STATEMENT)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	instructors := make([]courseload.Instructor, 0)
+
+	for rows.Next() {
+		var instructor courseload.Instructor
+		err = rows.Scan(&instructor.LastName, &instructor.FirstName, &instructor.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		instructors = append(instructors, instructor)
+	}
+
+	return instructors, rows.Err()
+}
+
+// Whether an instructor can be emailed. Placeholders such as "Staff" are
+// listed without an email, so they never are, while a real address on a
+// placeholder name is still reachable
+func reachable(email string) bool {
+	email, err := normalizeEmail(email)
+	return err == nil && email != ""
+}
+
+// A course's instructors that can be emailed, in the course's order
+func GetInstructorsWithEmail(crn string) ([]courseload.Instructor, error) {
+	return defaultStore.GetInstructorsWithEmail(crn)
+}
+
+func (s *Store) GetInstructorsWithEmail(crn string) ([]courseload.Instructor, error) {
+	course, err := s.GetCourse(crn)
+	if err != nil {
+		return nil, err
+	}
+
+	instructors := make([]courseload.Instructor, 0, len(course.Data.Instructors))
+
+	for _, instructor := range course.Data.Instructors {
+		if reachable(instructor.Email) {
+			instructors = append(instructors, instructor)
+		}
+	}
+
+	return instructors, nil
+}
+
+// The number of instructors teaching an active course who can't be
+// emailed. Each instructor counts once however many courses they teach,
+// so a shared "Staff" placeholder is a single instructor
+func CountUnreachableInstructors() (int, error) {
+	return defaultStore.CountUnreachableInstructors()
+}
+
+func (s *Store) CountUnreachableInstructors() (int, error) {
+	rows, err := s.queryContext(context.Background(), SELECT_ACTIVE_INSTRUCTOR_EMAILS_STATEMENT)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	count := 0
+
+	for rows.Next() {
+		var email string
+		if err = rows.Scan(&email); err != nil {
+			return 0, err
+		}
+
+		if !reachable(email) {
+			count++
+		}
+	}
+
+	return count, rows.Err()
+}
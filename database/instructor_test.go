@@ -0,0 +1,185 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestGetCoursesByInstructor(t *testing.T) {
+	s := newTestStore(t)
+
+	jane := courseload.Instructor{LastName: "Doe", FirstName: "Jane", Email: "jane.doe@unh.edu"}
+	john := courseload.Instructor{LastName: "Doe", FirstName: "John", Email: "john.doe@unh.edu"}
+
+	for crn, instructors := range map[string][]courseload.Instructor{
+		"20241000001": {jane},
+		"20241000002": {john},
+		"20241000003": {john, jane},
+		"20241000004": {{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"}},
+	} {
+		course := testCourse(crn, "COMP", "400", "Course")
+		course.Data.Instructors = instructors
+
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		last, first string
+		want        []string
+	}{
+		{"Doe", "Jane", []string{"20241000001", "20241000003"}},
+		{"doe", "JOHN", []string{"20241000002", "20241000003"}},
+		{"Doe", "", []string{"20241000001", "20241000002", "20241000003"}},
+		{"Doe", "Sam", nil},
+		{"Smith", "", nil},
+	} {
+		courses, err := s.GetCoursesByInstructor(test.last, test.first)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var crns []string
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("GetCoursesByInstructor(%q, %q) = %v, want %v", test.last, test.first, crns, test.want)
+		}
+	}
+
+	if _, err := s.GetCoursesByInstructor("", "Jane"); err == nil {
+		t.Error("GetCoursesByInstructor with an empty last name succeeded")
+	}
+}
+
+func TestInstructorProfilesShared(t *testing.T) {
+	s := newTestStore(t)
+
+	staff := courseload.Instructor{LastName: "Staff", FirstName: "TBA"}
+
+	for i, email := range []string{"jane.doe@unh.edu", "Jane.Doe@UNH.edu"} {
+		course := testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course")
+		course.Data.Instructors = []courseload.Instructor{{LastName: "Doe", FirstName: "Jane", Email: email}, staff}
+
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := s.db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if n := count("SELECT COUNT(*) FROM instructor_profiles;"); n != 2 {
+		t.Errorf("got %d instructor profiles, want one for Jane Doe and one for Staff", n)
+	}
+
+	if n := count("SELECT COUNT(*) FROM instructor_profiles WHERE profile_key = 'jane.doe@unh.edu';"); n != 1 {
+		t.Errorf("got %d profiles keyed by Jane's email, want 1", n)
+	}
+
+	if n := count("SELECT COUNT(*) FROM course_instructors;"); n != 4 {
+		t.Errorf("got %d course links, want 4", n)
+	}
+
+	for _, crn := range []string{"20241000000", "20241000001"} {
+		course, err := s.GetCourse(crn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(course.Data.Instructors) != 2 || course.Data.Instructors[0].LastName != "Doe" || course.Data.Instructors[1] != staff {
+			t.Errorf("%s instructors = %+v, want Jane Doe and Staff", crn, course.Data.Instructors)
+		}
+	}
+}
+
+func TestListInstructors(t *testing.T) {
+	s := newTestStore(t)
+
+	if instructors, err := s.ListInstructors(); err != nil || len(instructors) != 0 {
+		t.Errorf("ListInstructors on an empty database = %v, %v, want none", instructors, err)
+	}
+
+	roe := courseload.Instructor{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"}
+	john := courseload.Instructor{LastName: "doe", FirstName: "John", Email: "john.doe@unh.edu"}
+	jane := courseload.Instructor{LastName: "Doe", FirstName: "Jane", Email: "jane.doe@unh.edu"}
+
+	for i, instructors := range [][]courseload.Instructor{{roe, jane}, {john}, {jane, roe}, {jane}} {
+		course := testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course")
+		course.Data.Instructors = instructors
+
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	instructors, err := s.ListInstructors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []courseload.Instructor{jane, john, roe}; !slices.Equal(instructors, want) {
+		t.Errorf("ListInstructors = %+v, want %+v", instructors, want)
+	}
+}
+
+func TestInstructorEmailReachability(t *testing.T) {
+	s := newTestStore(t)
+
+	jane := courseload.Instructor{LastName: "Doe", FirstName: "Jane", Email: "jane.doe@unh.edu"}
+	roe := courseload.Instructor{LastName: "Roe", FirstName: "Sam", Email: "sam.roe@unh.edu"}
+	staff := courseload.Instructor{LastName: "Staff", FirstName: "TBA"}
+	invalid := courseload.Instructor{LastName: "Smith", FirstName: "Bob", Email: "bob at unh"}
+
+	for crn, instructors := range map[string][]courseload.Instructor{
+		"20241000001": {staff, jane, invalid},
+		"20241000002": {staff, roe},
+		"20241000003": {{LastName: "Gone", FirstName: "Pat"}},
+	} {
+		course := testCourse(crn, "COMP", "400", "Course")
+		course.Data.Instructors = instructors
+
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only instructors of active courses are counted
+	if err := s.SoftDeleteCourse("20241000003"); err != nil {
+		t.Fatal(err)
+	}
+
+	for crn, want := range map[string][]courseload.Instructor{
+		"20241000001": {jane},
+		"20241000002": {roe},
+	} {
+		instructors, err := s.GetInstructorsWithEmail(crn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(instructors, want) {
+			t.Errorf("GetInstructorsWithEmail(%s) = %+v, want %+v", crn, instructors, want)
+		}
+	}
+
+	if _, err := s.GetInstructorsWithEmail("20241099999"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("GetInstructorsWithEmail for a missing course = %v, want ErrCourseNotFound", err)
+	}
+
+	if count, err := s.CountUnreachableInstructors(); err != nil || count != 2 {
+		t.Errorf("CountUnreachableInstructors = %d, %v, want Staff once and Bob Smith", count, err)
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import "context"
+
+type IntegrityReport struct {
+	// Messages from PRAGMA integrity_check, empty when sqlite reports "ok"
+	SQLite []string
+
+	// Child rows whose term_crn has no matching course
+	OrphanedInstructors []int
+	OrphanedMeetings    []int
+
+	// Meeting rows repeating an earlier row of the same course
+	DuplicateMeetings []int
+
+	// Users' saved CRNs that no longer exist, keyed by email
+	MissingUserClasses map[string][]string
+}
+
+func (r *IntegrityReport) OK() bool {
+	return len(r.SQLite) == 0 && len(r.OrphanedInstructors) == 0 && len(r.OrphanedMeetings) == 0 &&
+		len(r.DuplicateMeetings) == 0 && len(r.MissingUserClasses) == 0
+}
+
+// Runs sqlite's own integrity check plus the application level checks that
+// foreign keys would otherwise have caught
+func CheckIntegrity() (IntegrityReport, error) {
+	var report IntegrityReport
+	var err error
+
+	rows, err := QueuedQuery("PRAGMA integrity_check;")
+	if err != nil {
+		return report, err
+	}
+
+	for rows.Next() {
+		var message string
+		if err = rows.Scan(&message); err != nil {
+			rows.Close()
+			return report, err
+		}
+
+		if message != "ok" {
+			report.SQLite = append(report.SQLite, message)
+		}
+	}
+
+	rows.Close()
+
+	if report.OrphanedInstructors, err = selectIDs(SELECT_ORPHANED_INSTRUCTORS_STATEMENT); err != nil {
+		return report, err
+	}
+
+	if report.OrphanedMeetings, err = selectIDs(SELECT_ORPHANED_MEETINGS_STATEMENT); err != nil {
+		return report, err
+	}
+
+	if report.DuplicateMeetings, err = selectIDs(SELECT_DUPLICATE_MEETINGS_STATEMENT); err != nil {
+		return report, err
+	}
+
+	// A soft deleted course is still stored, so enrolling in one isn't a
+	// missing class
+	crns, err := defaultStore.selectCRNs(context.Background(), "SELECT term_crn FROM courses;")
+	if err != nil {
+		return report, err
+	}
+
+	known := make(map[string]bool, len(crns))
+
+	for _, crn := range crns {
+		known[crn] = true
+	}
+
+	rows, err = QueuedQuery("SELECT email, classes FROM users;")
+	if err != nil {
+		return report, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var email, courses string
+		if err = rows.Scan(&email, &courses); err != nil {
+			return report, err
+		}
+
+		for _, class := range parseClasses(courses) {
+			if class != "" && !known[class] {
+				if report.MissingUserClasses == nil {
+					report.MissingUserClasses = make(map[string][]string)
+				}
+
+				report.MissingUserClasses[email] = append(report.MissingUserClasses[email], class)
+			}
+		}
+	}
+
+	return report, rows.Err()
+}
+
+func selectIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := QueuedQuery(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int, 0)
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"hacknhbackend.eparker.dev/util"
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
 const COURSES_STATEMENT = `CREATE TABLE IF NOT EXISTS courses (
@@ -46,63 +50,271 @@ const USERS_STATEMENT = `CREATE TABLE IF NOT EXISTS users (
 	privilege INTEGER NOT NULL DEFAULT 0
 );`
 
+const ARCHIVE_COURSES_TABLE_STATEMENT = `CREATE TABLE IF NOT EXISTS archive_courses (
+    term_crn TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    subject_code TEXT NOT NULL,
+    course_number TEXT NOT NULL,
+    section_number TEXT NOT NULL,
+    description TEXT NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);`
+
+const ARCHIVE_INSTRUCTORS_TABLE_STATEMENT = `CREATE TABLE IF NOT EXISTS archive_instructors (
+    id INTEGER PRIMARY KEY,
+    last_name TEXT NOT NULL,
+    first_name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    term_crn TEXT NOT NULL
+);`
+
+const ARCHIVE_MEETINGS_TABLE_STATEMENT = `CREATE TABLE IF NOT EXISTS archive_meetings (
+    id INTEGER PRIMARY KEY,
+    days TEXT NOT NULL,
+    building TEXT NOT NULL,
+    room TEXT NOT NULL,
+    time TEXT NOT NULL,
+    term_crn TEXT NOT NULL
+);`
+
+const SCHEMA_VERSION_STATEMENT = `CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at DATETIME NOT NULL
+);`
+
+const SCRAPE_RUNS_STATEMENT = `CREATE TABLE IF NOT EXISTS scrape_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    added INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    removed INTEGER NOT NULL DEFAULT 0,
+    success INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);`
+
+const SESSIONS_STATEMENT = `CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT PRIMARY KEY,
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);`
+
+const INDEXES_STATEMENT = `CREATE INDEX IF NOT EXISTS courses_subject_number ON courses (subject_code, course_number);
+CREATE INDEX IF NOT EXISTS instructors_term_crn ON instructors (term_crn);
+CREATE INDEX IF NOT EXISTS meetings_term_crn ON meetings (term_crn);`
+
 const INSERT_USER_STATEMENT = `INSERT INTO users (email, first_name, last_name, password, classes) VALUES (?, ?, ?, ?, ?);`
-const INSERT_INSTUCTOR_STATEMENT = `INSERT INTO instructors (last_name, first_name, email, term_crn) VALUES (?, ?, ?, ?);`
 const INSERT_MEETING_STATEMENT = `INSERT INTO meetings (days, building, room, time, term_crn) VALUES (?, ?, ?, ?, ?);`
-const INSERT_COURSE_STATEMENT = `INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description) VALUES (?, ?, ?, ?, ?, ?);`
+const INSERT_COURSE_STATEMENT = `INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+const UPDATE_COURSE_STATEMENT = `UPDATE courses SET title = ?, subject_code = ?, course_number = ?, section_number = ?, description = ?, seats_total = ?, seats_available = ?, waitlist_count = ?, credits = ?, updated_at = ? WHERE term_crn = ?;`
+const UPSERT_COURSE_STATEMENT = `INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (term_crn) DO UPDATE SET title = excluded.title, subject_code = excluded.subject_code, course_number = excluded.course_number,
+section_number = excluded.section_number, description = excluded.description, seats_total = excluded.seats_total,
+seats_available = excluded.seats_available, waitlist_count = excluded.waitlist_count, credits = excluded.credits, updated_at = excluded.updated_at;`
+
+// Instructors are identified by email, or by name for those without one.
+// The stored names and email follow the most recent scrape. The feed only
+// sometimes has office details, so a course without them keeps those known
+const UPSERT_INSTRUCTOR_PROFILE_STATEMENT = `INSERT INTO instructor_profiles (profile_key, last_name, first_name, email, office, office_hours)
+VALUES (CASE WHEN ?3 <> '' THEN lower(?3) ELSE lower(?1) || ',' || lower(?2) END, ?1, ?2, ?3, ?4, ?5)
+ON CONFLICT (profile_key) DO UPDATE SET last_name = excluded.last_name, first_name = excluded.first_name, email = excluded.email,
+office = COALESCE(NULLIF(excluded.office, ''), office), office_hours = COALESCE(NULLIF(excluded.office_hours, ''), office_hours)
+RETURNING id;`
+const INSERT_COURSE_INSTRUCTOR_STATEMENT = `INSERT INTO course_instructors (term_crn, instructor_id) VALUES (?, ?);`
 
-const SELECT_USER_STATEMENT = `SELECT id, email, first_name, last_name, password, classes, privilege FROM users WHERE email = ?;`
-const SELECT_COUSE_STATEMENT = `SELECT term_crn, title, subject_code, course_number, section_number, description FROM courses WHERE term_crn = ?;`
-const SELECT_INSTRUCTORS_STATEMENT = `SELECT id, last_name, first_name, email FROM instructors WHERE term_crn = ?;`
+const SELECT_USER_STATEMENT = `SELECT id, email, first_name, last_name, classes, privilege FROM users WHERE email = ?;`
+const SELECT_USERS_STATEMENT = `SELECT id, email, first_name, last_name, classes, privilege FROM users;`
+const SELECT_COUSE_STATEMENT = `SELECT term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, created_at, updated_at FROM courses WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;`
+const SELECT_INSTRUCTORS_STATEMENT = `SELECT ci.id, p.last_name, p.first_name, p.email, p.office, p.office_hours
+FROM course_instructors ci JOIN instructor_profiles p ON p.id = ci.instructor_id
+WHERE ci.term_crn = ? ORDER BY ci.id;`
 const SELECT_MEETINGS_STATEMENT = `SELECT id, days, building, room, time FROM meetings WHERE term_crn = ?;`
 
+// Formatted with the placeholders for a batch of CRNs
+const SELECT_COURSES_IN_STATEMENT = `SELECT term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, created_at, updated_at FROM courses WHERE term_crn COLLATE NOCASE IN (%s) AND archived_at IS NULL;`
+const SELECT_INSTRUCTORS_IN_STATEMENT = `SELECT ci.term_crn, p.last_name, p.first_name, p.email, p.office, p.office_hours
+FROM course_instructors ci JOIN instructor_profiles p ON p.id = ci.instructor_id
+WHERE ci.term_crn IN (%s) ORDER BY ci.id;`
+const SELECT_MEETINGS_IN_STATEMENT = `SELECT term_crn, id, days, building, room, time FROM meetings WHERE term_crn IN (%s) ORDER BY id;`
+
+const SELECT_ARCHIVED_COURSE_STATEMENT = `SELECT term_crn, title, subject_code, course_number, section_number, description, seats_total, seats_available, waitlist_count, credits, NULL, NULL FROM archive_courses WHERE term_crn = ? COLLATE NOCASE;`
+const SELECT_ARCHIVED_INSTRUCTORS_STATEMENT = `SELECT id, last_name, first_name, email, office, office_hours FROM archive_instructors WHERE term_crn = ?;`
+const SELECT_ARCHIVED_MEETINGS_STATEMENT = `SELECT id, days, building, room, time FROM archive_meetings WHERE term_crn = ?;`
+
+const SELECT_ORPHANED_INSTRUCTORS_STATEMENT = `SELECT id FROM course_instructors WHERE term_crn NOT IN (SELECT term_crn FROM courses) ORDER BY id;`
+const SELECT_ORPHANED_MEETINGS_STATEMENT = `SELECT id FROM meetings WHERE term_crn NOT IN (SELECT term_crn FROM courses) ORDER BY id;`
+const SELECT_DUPLICATE_MEETINGS_STATEMENT = `SELECT id FROM meetings m WHERE EXISTS (
+    SELECT 1 FROM meetings o WHERE o.term_crn = m.term_crn AND o.days = m.days AND o.building = m.building
+    AND o.room = m.room AND o.time = m.time AND o.id < m.id
+) ORDER BY id;`
+
+const SELECT_CO_INSTRUCTORS_STATEMENT = `SELECT DISTINCT other.last_name, other.first_name, other.email
+FROM instructor_profiles self
+JOIN course_instructors own ON own.instructor_id = self.id
+JOIN course_instructors shared ON shared.term_crn = own.term_crn
+JOIN instructor_profiles other ON other.id = shared.instructor_id
+WHERE self.email = ? COLLATE NOCASE AND other.id <> self.id
+ORDER BY other.last_name, other.first_name, other.email;`
+
+// Profiles outlive their courses, so only those still linked are listed
+const SELECT_LIST_INSTRUCTORS_STATEMENT = `SELECT p.last_name, p.first_name, p.email FROM instructor_profiles p
+WHERE EXISTS (SELECT 1 FROM course_instructors ci WHERE ci.instructor_id = p.id)
+ORDER BY p.last_name COLLATE NOCASE, p.first_name COLLATE NOCASE, p.email;`
+
+// Instructors of active courses, each profile once
+const SELECT_ACTIVE_INSTRUCTOR_EMAILS_STATEMENT = `SELECT p.email FROM instructor_profiles p
+WHERE EXISTS (SELECT 1 FROM course_instructors ci JOIN courses c ON c.term_crn = ci.term_crn WHERE ci.instructor_id = p.id AND c.archived_at IS NULL);`
+
+const SELECT_COURSES_BY_INSTRUCTOR_STATEMENT = `SELECT DISTINCT courses.term_crn
+FROM instructor_profiles p
+JOIN course_instructors ci ON ci.instructor_id = p.id
+JOIN courses ON courses.term_crn = ci.term_crn
+WHERE p.last_name = ? COLLATE NOCASE AND (? = '' OR p.first_name = ? COLLATE NOCASE)
+ORDER BY courses.term_crn;`
+
 const (
 	maxRetries = 5
 	baseDelay  = 100 * time.Millisecond
 )
 
-var db *sql.DB
+// SQLite allows a single writer at a time. WAL lets readers carry on while
+// a write is in progress, and busy_timeout has a blocked writer wait up to
+// 5s for the lock instead of failing straight away with SQLITE_BUSY. The
+// driver runs these on every new connection, which matters for
+// foreign_keys since SQLite leaves it off per connection by default
+const connectionPragmas = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+
+// Idle connections are closed after a minute so the pool shrinks back
+// down once a burst of requests is over
+const connMaxIdleTime = time.Minute
+
+// The in-memory databases opened so far, so each ":memory:" open gets a
+// database of its own
+var memoryDatabases atomic.Uint64
 
 func OpenDatabase() (*sql.DB, error) {
+	return OpenDatabaseAt(util.Config.Database.FileName)
+}
+
+// Opens the database at path, which may be ":memory:" for a throwaway
+// database that lives until it's closed
+func OpenDatabaseAt(path string) (*sql.DB, error) {
+	var handle *sql.DB
 	var err error
 
+	// Functions must be registered before the first connection is opened
+	registerFunctions.Do(func() {
+		err = sqlite.RegisterDeterministicScalarFunction("regexp", 2, sqlRegexp)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
 	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("sqlite", util.Config.Database.FileName)
+		handle, err = sql.Open(timedDriverName, databaseDSN(path))
 		if err == nil {
+			// An in-memory database is freed with its last connection, so
+			// its idle connections are kept
+			if path != ":memory:" {
+				handle.SetConnMaxIdleTime(connMaxIdleTime)
+			}
+
 			break
 		}
 
+		if i < maxRetries-1 {
+			retryAttempts.Add(1)
+		}
+
 		time.Sleep(baseDelay * time.Duration(i))
 	}
 
-	return db, err
+	if err != nil {
+		retryExhaustions.Add(1)
+	}
+
+	return handle, err
+}
+
+// Appends connectionPragmas to the file name, keeping any query parameters
+// already on it
+func databaseDSN(fileName string) string {
+	// Plain ":memory:" gives every pooled connection a separate database,
+	// whereas the memdb VFS shares one between them
+	if fileName == ":memory:" {
+		fileName = fmt.Sprintf("file:/memory-%d?vfs=memdb", memoryDatabases.Add(1))
+	}
+
+	if strings.Contains(fileName, "?") {
+		return fileName + "&" + connectionPragmas
+	}
+
+	return fileName + "?" + connectionPragmas
 }
 
 func Init() {
-	db, err := OpenDatabase()
-	if err != nil {
-		panic(err)
+	InitAt(util.Config.Database.FileName)
+}
+
+// Opens the database at path as the default Store and brings its schema
+// up to date. A database already open is closed first, along with its
+// prepared statements and cached courses
+func InitAt(path string) {
+	if defaultStore.db != nil {
+		defaultStore.closeStatements()
+		defaultStore.cache.clear()
+		defaultStore.db.Close()
+		defaultStore.db = nil
 	}
 
-	_, err = db.Exec(USERS_STATEMENT)
+	handle, err := OpenDatabaseAt(path)
 	if err != nil {
 		panic(err)
 	}
 
-	_, err = db.Exec(COURSES_STATEMENT)
+	defaultStore.db = handle
+
+	err = defaultStore.migrate(len(migrations))
 	if err != nil {
 		panic(err)
 	}
+}
+
+// Refreshes the query planner's statistics, run after bulk imports so the
+// indexes are used right away instead of after the next auto-analyze
+func AnalyzeIndexes() error {
+	return QueuedExec("ANALYZE;")
+}
 
-	_, err = db.Exec(INSTRUCTORS_STATEMENT)
+// Rebuilds the database file to reclaim the space left by deleted rows.
+// SQLite refuses to VACUUM inside a transaction, so this runs on its own
+func Vacuum() error {
+	return QueuedExec("VACUUM;")
+}
+
+// Copies the WAL back into the database file and truncates it, which
+// otherwise only happens once the last connection closes. Outside WAL mode
+// there's nothing to do and this returns nil
+func Checkpoint() error {
+	return defaultStore.Checkpoint()
+}
+
+func (s *Store) Checkpoint() error {
+	var busy, logFrames, checkpointed int
+
+	err := s.queryRowContext(context.Background(), "PRAGMA wal_checkpoint(TRUNCATE);").Scan(&busy, &logFrames, &checkpointed)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	_, err = db.Exec(MEETINGS_STATEMENT)
-	if err != nil {
-		panic(err)
+	if busy != 0 {
+		return fmt.Errorf("checkpoint blocked by another connection, %d of %d frames checkpointed", checkpointed, logFrames)
 	}
+
+	return nil
 }
 
 // Queue system
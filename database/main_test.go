@@ -0,0 +1,238 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// A Store on a fresh database file, closed when the test ends
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := NewStore(t.TempDir() + "/db.sqlite")
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// Points the package level functions at a fresh database file for the
+// length of the test
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+
+	InitAt(t.TempDir() + "/db.sqlite")
+
+	t.Cleanup(func() {
+		CloseDatabase()
+		ClearCache()
+	})
+}
+
+func testCourse(crn, subject, number, title string, meetings ...courseload.Meeting) courseload.Course {
+	return courseload.Course{
+		CRN: crn,
+		Data: courseload.CourseData{
+			Title:       title,
+			Subject:     subject,
+			Number:      number,
+			Description: "About " + title,
+			Instructors: []courseload.Instructor{{LastName: "Doe", FirstName: "Jane", Email: "jane.doe@unh.edu"}},
+			Meetings:    append([]courseload.Meeting{}, meetings...),
+			SectionNum:  "01",
+		},
+	}
+}
+
+func testMeeting(days, times string) courseload.Meeting {
+	return courseload.Meeting{Days: days, Building: "Kingsbury", Room: "N101", Time: times}
+}
+
+func TestConcurrentInsertCourse(t *testing.T) {
+	s := newTestStore(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), "COMP", "405", "Software Engineering", testMeeting("MWF", "10:10am-11:00am")))
+		}()
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("insert %d: %v", i, err)
+		}
+	}
+
+	count, err := s.CountCourses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != len(errs) {
+		t.Errorf("got %d courses, want %d", count, len(errs))
+	}
+}
+
+func TestInitThenQuery(t *testing.T) {
+	previous := util.Config.Database.FileName
+	util.Config.Database.FileName = t.TempDir() + "/db.sqlite"
+
+	t.Cleanup(func() {
+		util.Config.Database.FileName = previous
+		CloseDatabase()
+		ClearCache()
+	})
+
+	Init()
+
+	crns, err := GetCourseCRNs()
+	if err != nil || len(crns) != 0 {
+		t.Fatalf("GetCourseCRNs on a new database = %v, %v", crns, err)
+	}
+
+	if err = InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	if crns, err = GetCourseCRNs(); err != nil || len(crns) != 1 || crns[0] != "20241000001" {
+		t.Errorf("GetCourseCRNs = %v, %v, want [20241000001]", crns, err)
+	}
+}
+
+func TestInitAtMemory(t *testing.T) {
+	InitAt(":memory:")
+
+	t.Cleanup(func() {
+		CloseDatabase()
+		ClearCache()
+	})
+
+	if version, err := SchemaVersion(); err != nil || version != len(migrations) {
+		t.Errorf("SchemaVersion = %d, %v, want %d", version, err, len(migrations))
+	}
+
+	tables := map[string]bool{}
+
+	rows, err := defaultStore.db.Query("SELECT name FROM sqlite_master WHERE type = 'table';")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables[name] = true
+	}
+
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{
+		"courses", "meetings", "course_instructors", "instructor_profiles", "users", "sessions",
+		"watches", "scrape_runs", "schema_version", "archive_courses", "availability_snapshots", "courses_fts",
+	} {
+		if !tables[table] {
+			t.Errorf("no %s table in the in-memory database", table)
+		}
+	}
+
+	if tables["instructors"] {
+		t.Error("the instructors table migration 4 replaces is still there")
+	}
+
+	if err = InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	// An open transaction holds one connection, so the count runs on
+	// another and only sees the course if the connections share a database
+	transaction, err := defaultStore.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer transaction.Rollback()
+
+	if err = transaction.QueryRow("SELECT COUNT(*) FROM courses;").Scan(new(int)); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := CountCourses(); err != nil || count != 1 {
+		t.Errorf("CountCourses on another connection = %d, %v, want 1", count, err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := t.TempDir() + "/db.sqlite"
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	walSize := func() int64 {
+		t.Helper()
+
+		info, err := os.Stat(path + "-wal")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return info.Size()
+	}
+
+	for i := range 300 {
+		if err = s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if size := walSize(); size == 0 {
+		t.Fatal("the WAL is empty after 300 inserts")
+	}
+
+	if err = s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	if size := walSize(); size != 0 {
+		t.Errorf("the WAL is %d bytes after a checkpoint, want 0", size)
+	}
+
+	courses := make([]courseload.Course, 300)
+	for i := range courses {
+		courses[i] = testCourse(fmt.Sprintf("202420%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+	}
+
+	if err = s.BulkInsertCourses(courses); err != nil {
+		t.Fatal(err)
+	}
+
+	if size := walSize(); size != 0 {
+		t.Errorf("the WAL is %d bytes after a bulk insert, want it checkpointed", size)
+	}
+
+	if count, err := s.CountCourses(); err != nil || count != 600 {
+		t.Errorf("CountCourses after checkpointing = %d, %v, want 600", count, err)
+	}
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// A course's meetings along with their IDs, for editing a single meeting
+func GetMeetings(crn string) ([]courseload.Meeting, error) {
+	course, err := GetCourse(crn)
+	if err != nil {
+		return nil, err
+	}
+
+	return course.Data.Meetings, nil
+}
+
+// Deletes one meeting by the ID GetMeetings reports, leaving the rest of
+// its course as is
+func DeleteMeeting(id int) error {
+	var term_crn string
+
+	err := QueuedQueryRow("DELETE FROM meetings WHERE id = ? RETURNING term_crn;", id).Scan(&term_crn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrMeetingNotFound, id)
+	} else if err != nil {
+		return err
+	}
+
+	defaultStore.cache.remove(term_crn)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeleteMeeting(t *testing.T) {
+	useTestDatabase(t)
+
+	course := testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"), testMeeting("R", "2:10pm-3:00pm"))
+	if err := InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InsertCourse(testCourse("20241000002", "COMP", "401", "Other", testMeeting("TR", "9:40am-10:55am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	meetings, err := GetMeetings(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(meetings) != 2 || meetings[0].ID == 0 || meetings[0].ID == meetings[1].ID {
+		t.Fatalf("GetMeetings = %+v, want two meetings with their own IDs", meetings)
+	}
+
+	// Loaded into the cache, so the delete has to drop it from there too
+	if _, err = GetCourse(course.CRN); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = DeleteMeeting(meetings[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, err := GetMeetings(course.CRN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(remaining) != 1 || remaining[0] != meetings[0] {
+		t.Errorf("meetings after the delete = %+v, want only %+v", remaining, meetings[0])
+	}
+
+	if other, _ := GetMeetings("20241000002"); len(other) != 1 {
+		t.Errorf("the other course has %d meetings, want 1", len(other))
+	}
+
+	if err = DeleteMeeting(meetings[1].ID); !errors.Is(err, ErrMeetingNotFound) {
+		t.Errorf("deleting the meeting again = %v, want ErrMeetingNotFound", err)
+	}
+
+	if _, err = GetMeetings("20241099999"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("GetMeetings for a missing course = %v, want ErrCourseNotFound", err)
+	}
+}
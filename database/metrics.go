@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+type DatabaseMetrics struct {
+	// Statements run by every Store, transactions begun included
+	Queries uint64
+
+	// Failed attempts that were tried again
+	RetryAttempts uint64
+
+	// Operations that still failed after maxRetries attempts
+	RetryExhaustions uint64
+}
+
+var queries, retryAttempts, retryExhaustions atomic.Uint64
+
+func Metrics() DatabaseMetrics {
+	return DatabaseMetrics{
+		Queries:          queries.Load(),
+		RetryAttempts:    retryAttempts.Load(),
+		RetryExhaustions: retryExhaustions.Load(),
+	}
+}
+
+type DBStats struct {
+	// Rows in each table, keyed by table name
+	Rows map[string]int
+
+	// The size of the database file, not counting the WAL
+	SizeBytes int64
+}
+
+func Stats() (DBStats, error) {
+	stats := DBStats{Rows: make(map[string]int)}
+
+	// Leaves out SQLite's own tables and the shadow tables behind courses_fts
+	rows, err := QueuedQuery("SELECT name FROM pragma_table_list WHERE schema = 'main' AND type IN ('table', 'virtual') AND name NOT LIKE 'sqlite_%' ORDER BY name;")
+	if err != nil {
+		return stats, err
+	}
+
+	var tables []string
+
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return stats, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, table := range tables {
+		var count int
+
+		// Table names come from SQLite rather than user input
+		err = QueuedQueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s";`, table)).Scan(&count)
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Rows[table] = count
+	}
+
+	err = QueuedQueryRow("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size();").Scan(&stats.SizeBytes)
+
+	return stats, err
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVacuumAndStats(t *testing.T) {
+	useTestDatabase(t)
+
+	if _, status := CreateUser("a@unh.edu", "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	for i := range 200 {
+		course := testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+		course.Data.Description = strings.Repeat("A long description. ", 100)
+
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, err := Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for table, want := range map[string]int{"courses": 200, "meetings": 200, "course_instructors": 200, "users": 1, "courses_fts": 200} {
+		if full.Rows[table] != want {
+			t.Errorf("Stats counted %d rows in %s, want %d", full.Rows[table], table, want)
+		}
+	}
+
+	for table := range full.Rows {
+		if strings.HasPrefix(table, "sqlite_") || strings.HasPrefix(table, "courses_fts_") {
+			t.Errorf("Stats counted internal table %s", table)
+		}
+	}
+
+	if err = QueuedExec("DELETE FROM courses;"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+
+	vacuumed, err := Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vacuumed.Rows["courses"] != 0 || vacuumed.Rows["meetings"] != 0 || vacuumed.Rows["users"] != 1 {
+		t.Errorf("rows after deleting courses = %v", vacuumed.Rows)
+	}
+
+	if vacuumed.SizeBytes <= 0 || vacuumed.SizeBytes >= full.SizeBytes {
+		t.Errorf("size after vacuuming = %d bytes, want less than the %d before", vacuumed.SizeBytes, full.SizeBytes)
+	}
+}
@@ -0,0 +1,476 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hacknhbackend.eparker.dev/util"
+)
+
+// A schema change. Versions start at 1 and increase by one, and a
+// migration is never edited once released, only followed by another
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+
+	// Undoes Up, for MigrateDown. Left empty when a migration can't be
+	// undone
+	Down string
+}
+
+// Migration 1 is the schema as it stood before migrations were tracked.
+// Its statements are all IF NOT EXISTS so it also applies cleanly to
+// databases created back then
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "initial schema",
+		Up: strings.Join([]string{
+			USERS_STATEMENT,
+			COURSES_STATEMENT,
+			INSTRUCTORS_STATEMENT,
+			MEETINGS_STATEMENT,
+			SCRAPE_RUNS_STATEMENT,
+			SESSIONS_STATEMENT,
+			INDEXES_STATEMENT,
+			ARCHIVE_COURSES_TABLE_STATEMENT,
+			ARCHIVE_INSTRUCTORS_TABLE_STATEMENT,
+			ARCHIVE_MEETINGS_TABLE_STATEMENT,
+		}, "\n"),
+	},
+	{
+		Version:     2,
+		Description: "course seats and waitlist",
+		Up: `ALTER TABLE courses ADD COLUMN seats_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE courses ADD COLUMN seats_available INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE courses ADD COLUMN waitlist_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE archive_courses ADD COLUMN seats_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE archive_courses ADD COLUMN seats_available INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE archive_courses ADD COLUMN waitlist_count INTEGER NOT NULL DEFAULT 0;`,
+		Down: `ALTER TABLE courses DROP COLUMN seats_total;
+ALTER TABLE courses DROP COLUMN seats_available;
+ALTER TABLE courses DROP COLUMN waitlist_count;
+ALTER TABLE archive_courses DROP COLUMN seats_total;
+ALTER TABLE archive_courses DROP COLUMN seats_available;
+ALTER TABLE archive_courses DROP COLUMN waitlist_count;`,
+	},
+	{
+		Version:     3,
+		Description: "course credits",
+		Up: `ALTER TABLE courses ADD COLUMN credits REAL NOT NULL DEFAULT 0;
+ALTER TABLE archive_courses ADD COLUMN credits REAL NOT NULL DEFAULT 0;`,
+		Down: `ALTER TABLE courses DROP COLUMN credits;
+ALTER TABLE archive_courses DROP COLUMN credits;`,
+	},
+	{
+		// Links keep the ids of the rows they replace, and their sequence
+		// continues from the old table's, so archived instructor ids stay
+		// unique
+		Version:     4,
+		Description: "instructor profiles",
+		Up: `CREATE TABLE instructor_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    profile_key TEXT NOT NULL UNIQUE,
+    last_name TEXT NOT NULL,
+    first_name TEXT NOT NULL,
+    email TEXT NOT NULL
+);
+CREATE TABLE course_instructors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    term_crn TEXT NOT NULL,
+    instructor_id INTEGER NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn),
+    FOREIGN KEY (instructor_id) REFERENCES instructor_profiles(id)
+);
+CREATE INDEX course_instructors_term_crn ON course_instructors (term_crn);
+CREATE INDEX course_instructors_instructor_id ON course_instructors (instructor_id);
+INSERT INTO instructor_profiles (profile_key, last_name, first_name, email)
+    SELECT CASE WHEN email <> '' THEN lower(email) ELSE lower(last_name) || ',' || lower(first_name) END, last_name, first_name, email
+    FROM instructors WHERE id IN (
+        SELECT MAX(id) FROM instructors
+        GROUP BY CASE WHEN email <> '' THEN lower(email) ELSE lower(last_name) || ',' || lower(first_name) END
+    ) ORDER BY id;
+INSERT INTO course_instructors (id, term_crn, instructor_id)
+    SELECT i.id, i.term_crn, p.id FROM instructors i JOIN instructor_profiles p
+    ON p.profile_key = CASE WHEN i.email <> '' THEN lower(i.email) ELSE lower(i.last_name) || ',' || lower(i.first_name) END
+    ORDER BY i.id;
+DELETE FROM sqlite_sequence WHERE name = 'course_instructors';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'course_instructors', seq FROM sqlite_sequence WHERE name = 'instructors';
+DROP TABLE instructors;`,
+		Down: INSTRUCTORS_STATEMENT + `
+CREATE INDEX instructors_term_crn ON instructors (term_crn);
+INSERT INTO instructors (id, last_name, first_name, email, term_crn)
+    SELECT ci.id, p.last_name, p.first_name, p.email, ci.term_crn FROM course_instructors ci
+    JOIN instructor_profiles p ON p.id = ci.instructor_id ORDER BY ci.id;
+DELETE FROM sqlite_sequence WHERE name = 'instructors';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'instructors', seq FROM sqlite_sequence WHERE name = 'course_instructors';
+DROP TABLE course_instructors;
+DROP TABLE instructor_profiles;`,
+	},
+	{
+		// Keyed on term_crn rather than rowid since VACUUM may renumber the
+		// rowids of courses, which has no INTEGER PRIMARY KEY
+		Version:     5,
+		Description: "course full-text search",
+		Up: `CREATE VIRTUAL TABLE courses_fts USING fts5(
+    term_crn UNINDEXED,
+    title,
+    description,
+    subject_code,
+    tokenize = 'porter unicode61'
+);
+INSERT INTO courses_fts (courses_fts, rank) VALUES ('rank', 'bm25(0.0, 10.0, 1.0, 5.0)');
+INSERT INTO courses_fts (term_crn, title, description, subject_code) SELECT term_crn, title, description, subject_code FROM courses;
+CREATE TRIGGER courses_fts_insert AFTER INSERT ON courses BEGIN
+    INSERT INTO courses_fts (term_crn, title, description, subject_code) VALUES (new.term_crn, new.title, new.description, new.subject_code);
+END;
+CREATE TRIGGER courses_fts_delete AFTER DELETE ON courses BEGIN
+    DELETE FROM courses_fts WHERE term_crn = old.term_crn;
+END;
+CREATE TRIGGER courses_fts_update AFTER UPDATE OF term_crn, title, description, subject_code ON courses BEGIN
+    DELETE FROM courses_fts WHERE term_crn = old.term_crn;
+    INSERT INTO courses_fts (term_crn, title, description, subject_code) VALUES (new.term_crn, new.title, new.description, new.subject_code);
+END;`,
+		Down: `DROP TRIGGER courses_fts_insert;
+DROP TRIGGER courses_fts_delete;
+DROP TRIGGER courses_fts_update;
+DROP TABLE courses_fts;`,
+	},
+	{
+		// The term is the leading YYYYTT code of term_crn, such as 202410,
+		// so it is generated from the key rather than stored alongside it.
+		// term_crn stays the primary key since a CRN is only unique within
+		// its term
+		Version:     6,
+		Description: "course term",
+		Up: `ALTER TABLE courses ADD COLUMN term TEXT NOT NULL GENERATED ALWAYS AS (
+    CASE WHEN term_crn GLOB '[0-9][0-9][0-9][0-9][0-9][0-9]*' THEN substr(term_crn, 1, 6) ELSE '' END
+) VIRTUAL;
+ALTER TABLE archive_courses ADD COLUMN term TEXT NOT NULL GENERATED ALWAYS AS (
+    CASE WHEN term_crn GLOB '[0-9][0-9][0-9][0-9][0-9][0-9]*' THEN substr(term_crn, 1, 6) ELSE '' END
+) VIRTUAL;
+CREATE INDEX courses_term ON courses (term);`,
+		Down: `DROP INDEX courses_term;
+ALTER TABLE courses DROP COLUMN term;
+ALTER TABLE archive_courses DROP COLUMN term;`,
+	},
+	{
+		Version:     7,
+		Description: "soft deleted courses",
+		Up: `ALTER TABLE courses ADD COLUMN archived_at TIMESTAMP NULL;
+CREATE INDEX courses_archived_at ON courses (archived_at);`,
+		// Soft deleted courses become active again
+		Down: `DROP INDEX courses_archived_at;
+ALTER TABLE courses DROP COLUMN archived_at;`,
+	},
+	{
+		Version:     8,
+		Description: "instructor office and office hours",
+		Up: `ALTER TABLE instructor_profiles ADD COLUMN office TEXT NOT NULL DEFAULT '';
+ALTER TABLE instructor_profiles ADD COLUMN office_hours TEXT NOT NULL DEFAULT '';
+ALTER TABLE archive_instructors ADD COLUMN office TEXT NOT NULL DEFAULT '';
+ALTER TABLE archive_instructors ADD COLUMN office_hours TEXT NOT NULL DEFAULT '';`,
+		Down: `ALTER TABLE instructor_profiles DROP COLUMN office;
+ALTER TABLE instructor_profiles DROP COLUMN office_hours;
+ALTER TABLE archive_instructors DROP COLUMN office;
+ALTER TABLE archive_instructors DROP COLUMN office_hours;`,
+	},
+	{
+		// last_seats_available is the seat count CheckWatches last saw, so
+		// each opening is reported once
+		Version:     9,
+		Description: "course watches",
+		Up: `CREATE TABLE watches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,
+    term_crn TEXT NOT NULL,
+    last_seats_available INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (email, term_crn),
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn)
+);
+CREATE INDEX watches_term_crn ON watches (term_crn);`,
+		Down: `DROP TABLE watches;`,
+	},
+	{
+		// SQLite can't add ON DELETE CASCADE to an existing table, so each
+		// child of courses is rebuilt. Rows whose course is already gone
+		// would fail the new constraint and are left behind, and ids and
+		// sequences carry over so archived ids stay unique
+		Version:     10,
+		Description: "cascade course deletes",
+		Up: `CREATE TABLE course_instructors_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    term_crn TEXT NOT NULL,
+    instructor_id INTEGER NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn) ON DELETE CASCADE,
+    FOREIGN KEY (instructor_id) REFERENCES instructor_profiles(id)
+);
+INSERT INTO course_instructors_new (id, term_crn, instructor_id)
+    SELECT id, term_crn, instructor_id FROM course_instructors WHERE term_crn IN (SELECT term_crn FROM courses);
+DELETE FROM sqlite_sequence WHERE name = 'course_instructors_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'course_instructors_new', seq FROM sqlite_sequence WHERE name = 'course_instructors';
+DROP TABLE course_instructors;
+ALTER TABLE course_instructors_new RENAME TO course_instructors;
+CREATE INDEX course_instructors_term_crn ON course_instructors (term_crn);
+CREATE INDEX course_instructors_instructor_id ON course_instructors (instructor_id);
+CREATE TABLE meetings_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    days TEXT NOT NULL,
+    building TEXT NOT NULL,
+    room TEXT NOT NULL,
+    time TEXT NOT NULL,
+    term_crn TEXT NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn) ON DELETE CASCADE
+);
+INSERT INTO meetings_new (id, days, building, room, time, term_crn)
+    SELECT id, days, building, room, time, term_crn FROM meetings WHERE term_crn IN (SELECT term_crn FROM courses);
+DELETE FROM sqlite_sequence WHERE name = 'meetings_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'meetings_new', seq FROM sqlite_sequence WHERE name = 'meetings';
+DROP TABLE meetings;
+ALTER TABLE meetings_new RENAME TO meetings;
+CREATE INDEX meetings_term_crn ON meetings (term_crn);
+CREATE TABLE watches_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,
+    term_crn TEXT NOT NULL,
+    last_seats_available INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (email, term_crn),
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn) ON DELETE CASCADE
+);
+INSERT INTO watches_new (id, email, term_crn, last_seats_available, created_at)
+    SELECT id, email, term_crn, last_seats_available, created_at FROM watches WHERE term_crn IN (SELECT term_crn FROM courses);
+DELETE FROM sqlite_sequence WHERE name = 'watches_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'watches_new', seq FROM sqlite_sequence WHERE name = 'watches';
+DROP TABLE watches;
+ALTER TABLE watches_new RENAME TO watches;
+CREATE INDEX watches_term_crn ON watches (term_crn);`,
+		Down: `CREATE TABLE course_instructors_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    term_crn TEXT NOT NULL,
+    instructor_id INTEGER NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn),
+    FOREIGN KEY (instructor_id) REFERENCES instructor_profiles(id)
+);
+INSERT INTO course_instructors_old (id, term_crn, instructor_id) SELECT id, term_crn, instructor_id FROM course_instructors;
+DELETE FROM sqlite_sequence WHERE name = 'course_instructors_old';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'course_instructors_old', seq FROM sqlite_sequence WHERE name = 'course_instructors';
+DROP TABLE course_instructors;
+ALTER TABLE course_instructors_old RENAME TO course_instructors;
+CREATE INDEX course_instructors_term_crn ON course_instructors (term_crn);
+CREATE INDEX course_instructors_instructor_id ON course_instructors (instructor_id);
+CREATE TABLE meetings_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    days TEXT NOT NULL,
+    building TEXT NOT NULL,
+    room TEXT NOT NULL,
+    time TEXT NOT NULL,
+    term_crn TEXT NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn)
+);
+INSERT INTO meetings_old (id, days, building, room, time, term_crn) SELECT id, days, building, room, time, term_crn FROM meetings;
+DELETE FROM sqlite_sequence WHERE name = 'meetings_old';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'meetings_old', seq FROM sqlite_sequence WHERE name = 'meetings';
+DROP TABLE meetings;
+ALTER TABLE meetings_old RENAME TO meetings;
+CREATE INDEX meetings_term_crn ON meetings (term_crn);
+CREATE TABLE watches_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,
+    term_crn TEXT NOT NULL,
+    last_seats_available INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (email, term_crn),
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn)
+);
+INSERT INTO watches_old (id, email, term_crn, last_seats_available, created_at) SELECT id, email, term_crn, last_seats_available, created_at FROM watches;
+DELETE FROM sqlite_sequence WHERE name = 'watches_old';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'watches_old', seq FROM sqlite_sequence WHERE name = 'watches';
+DROP TABLE watches;
+ALTER TABLE watches_old RENAME TO watches;
+CREATE INDEX watches_term_crn ON watches (term_crn);`,
+	},
+	{
+		// A course's seat counts as each scrape run left them, for
+		// GetAvailabilityHistory. run_id is NULL when the run couldn't be
+		// recorded
+		Version:     11,
+		Description: "course availability snapshots",
+		Up: `CREATE TABLE availability_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    term_crn TEXT NOT NULL,
+    run_id INTEGER NULL,
+    taken_at TIMESTAMP NOT NULL,
+    seats_total INTEGER NOT NULL,
+    seats_available INTEGER NOT NULL,
+    waitlist_count INTEGER NOT NULL,
+    FOREIGN KEY (term_crn) REFERENCES courses(term_crn) ON DELETE CASCADE,
+    FOREIGN KEY (run_id) REFERENCES scrape_runs(id)
+);
+CREATE INDEX availability_snapshots_term_crn ON availability_snapshots (term_crn, taken_at);`,
+		Down: `DROP TABLE availability_snapshots;`,
+	},
+	{
+		// Courses already stored count as created and updated by this
+		// migration. The backfill is written the way the driver writes a
+		// time.Time so it sorts with the timestamps written later
+		Version:     12,
+		Description: "course timestamps",
+		Up: `ALTER TABLE courses ADD COLUMN created_at TIMESTAMP NULL;
+ALTER TABLE courses ADD COLUMN updated_at TIMESTAMP NULL;
+UPDATE courses SET created_at = strftime('%Y-%m-%d %H:%M:%f', 'now') || ' +0000 UTC';
+UPDATE courses SET updated_at = created_at;
+CREATE INDEX courses_updated_at ON courses (updated_at);`,
+		Down: `DROP INDEX courses_updated_at;
+ALTER TABLE courses DROP COLUMN created_at;
+ALTER TABLE courses DROP COLUMN updated_at;`,
+	},
+}
+
+// The highest migration applied, 0 for an empty database
+func SchemaVersion() (int, error) {
+	return defaultStore.SchemaVersion()
+}
+
+func (s *Store) SchemaVersion() (int, error) {
+	var version int
+
+	if s.db == nil {
+		return 0, ErrDatabaseNotOpen
+	}
+
+	err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version;").Scan(&version)
+	return version, err
+}
+
+// Applies pending migrations up to and including target, as opening the
+// database does for every migration. Used to come back up after
+// MigrateDown
+func MigrateUp(target int) error {
+	return defaultStore.MigrateUp(target)
+}
+
+func (s *Store) MigrateUp(target int) error {
+	defer s.cache.clear()
+
+	return s.getQueue().EnqueueOperation(func() error {
+		if s.db == nil {
+			return ErrDatabaseNotOpen
+		}
+
+		s.closeStatements()
+
+		return s.migrate(target)
+	})
+}
+
+// Undoes applied migrations newest first until the schema is at target,
+// each in its own transaction. Stops with an error at a migration without
+// a Down, leaving the schema at the version after it
+func MigrateDown(target int) error {
+	return defaultStore.MigrateDown(target)
+}
+
+func (s *Store) MigrateDown(target int) error {
+	if target < 0 {
+		return fmt.Errorf("target version must not be negative")
+	}
+
+	// Prepared statements and cached courses may refer to columns that are
+	// about to go
+	defer s.cache.clear()
+
+	return s.getQueue().EnqueueOperation(func() error {
+		if s.db == nil {
+			return ErrDatabaseNotOpen
+		}
+
+		s.closeStatements()
+
+		current, err := s.SchemaVersion()
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if migration.Version > current || migration.Version <= target {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d (%s) can't be undone", migration.Version, migration.Description)
+			}
+
+			transaction, err := s.db.Begin()
+			if err != nil {
+				return err
+			}
+
+			if _, err = transaction.Exec(migration.Down); err != nil {
+				transaction.Rollback()
+				return fmt.Errorf("undoing migration %d (%s): %w", migration.Version, migration.Description, err)
+			}
+
+			if _, err = transaction.Exec("DELETE FROM schema_version WHERE version = ?;", migration.Version); err != nil {
+				transaction.Rollback()
+				return err
+			}
+
+			if err = transaction.Commit(); err != nil {
+				return err
+			}
+
+			util.Log.Status(fmt.Sprintf("Undid migration %d: %s", migration.Version, migration.Description))
+		}
+
+		return nil
+	})
+}
+
+// Applies pending migrations up to and including target, each in its own
+// transaction so a failure leaves the schema at the last good version
+func (s *Store) migrate(target int) error {
+	_, err := s.db.Exec(SCHEMA_VERSION_STATEMENT)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current || migration.Version > target {
+			continue
+		}
+
+		transaction, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err = transaction.Exec(migration.Up); err != nil {
+			transaction.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		_, err = transaction.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?);", migration.Version, migration.Description, time.Now().UTC())
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		if err = transaction.Commit(); err != nil {
+			return err
+		}
+
+		util.Log.Status(fmt.Sprintf("Applied migration %d: %s", migration.Version, migration.Description))
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func hasColumn(t *testing.T, s *Store, table, column string) bool {
+	t.Helper()
+
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;", table, column).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count > 0
+}
+
+// Every table, index and trigger along with the SQL creating it
+func schemaSQL(t *testing.T, s *Store) string {
+	t.Helper()
+
+	rows, err := s.db.Query("SELECT name, COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY name;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	var schema []string
+
+	for rows.Next() {
+		var name, sql string
+		if err = rows.Scan(&name, &sql); err != nil {
+			t.Fatal(err)
+		}
+
+		schema = append(schema, name+": "+sql)
+	}
+
+	return strings.Join(schema, "\n")
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	handle, err := OpenDatabaseAt(t.TempDir() + "/db.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Store{db: handle, cache: newCourseCache(defaultCacheSize)}
+	t.Cleanup(func() { handle.Close() })
+
+	if err = s.migrate(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err := s.SchemaVersion(); err != nil || version != 2 {
+		t.Fatalf("schema version = %d, %v, want 2", version, err)
+	}
+
+	if !hasColumn(t, s, "courses", "seats_available") {
+		t.Error("courses has no seats_available column after migration 2")
+	}
+
+	if hasColumn(t, s, "courses", "credits") {
+		t.Error("courses has a credits column before migration 3")
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241012345", "COMP", "405", "Software Engineering", testMeeting("TR", "9:30am-10:50am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	latest := schemaSQL(t, s)
+
+	if err := s.MigrateDown(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, _ := s.SchemaVersion(); version != 3 {
+		t.Fatalf("schema version = %d after MigrateDown(3), want 3", version)
+	}
+
+	if hasColumn(t, s, "courses", "archived_at") || !hasColumn(t, s, "courses", "credits") {
+		t.Error("MigrateDown(3) didn't leave the version 3 courses table")
+	}
+
+	var instructors int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM instructors;").Scan(&instructors); err != nil || instructors != 1 {
+		t.Errorf("instructors = %d, %v after undoing instructor profiles, want 1", instructors, err)
+	}
+
+	if err := s.MigrateUp(len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema := schemaSQL(t, s); schema != latest {
+		t.Errorf("schema after migrating down and up differs:\n%s\nwant:\n%s", schema, latest)
+	}
+
+	course, err := s.GetCourse("20241012345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(course.Data.Instructors) != 1 || len(course.Data.Meetings) != 1 {
+		t.Errorf("course lost children in the round trip: %+v", course.Data)
+	}
+}
+
+func TestMigrateDownStopsAtInitialSchema(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.MigrateDown(0); err == nil {
+		t.Fatal("MigrateDown(0) undid the initial schema")
+	}
+
+	if version, _ := s.SchemaVersion(); version != 1 {
+		t.Errorf("schema version = %d, want 1", version)
+	}
+}
+
+func TestDeleteCourseCascades(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, crn := range []string{"20241000001", "20241000002"} {
+		if err := s.InsertCourse(testCourse(crn, "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.db.Exec("INSERT INTO watches (email, term_crn, last_seats_available, created_at) VALUES ('a@unh.edu', ?, 0, ?);", crn, time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.DeleteCourse("20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"meetings", "course_instructors", "watches"} {
+		var deleted, kept int
+		if err := s.db.QueryRow("SELECT COUNT(*) FILTER (WHERE term_crn = '20241000001'), COUNT(*) FILTER (WHERE term_crn = '20241000002') FROM "+table+";").Scan(&deleted, &kept); err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted != 0 || kept != 1 {
+			t.Errorf("%s has %d rows of the deleted course and %d of the other, want 0 and 1", table, deleted, kept)
+		}
+	}
+
+	// The instructor profile is shared, so it outlives its courses
+	var profiles int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM instructor_profiles;").Scan(&profiles); err != nil || profiles != 1 {
+		t.Errorf("instructor_profiles = %d, %v, want 1", profiles, err)
+	}
+}
+
+func TestMigrateDownCascade(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.db.Exec("INSERT INTO watches (email, term_crn, last_seats_available, created_at) VALUES ('a@unh.edu', '20241000001', 0, ?);", time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MigrateDown(9); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema := schemaSQL(t, s); strings.Contains(schema, "ON DELETE CASCADE") {
+		t.Errorf("schema still cascades after undoing migration 10:\n%s", schema)
+	}
+
+	for _, table := range []string{"meetings", "course_instructors", "watches"} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table + ";").Scan(&count); err != nil || count != 1 {
+			t.Errorf("%s has %d rows, %v after undoing migration 10, want 1", table, count, err)
+		}
+	}
+
+	// Without the cascade the foreign keys refuse to orphan the children
+	if _, err := s.db.Exec("DELETE FROM courses WHERE term_crn = '20241000001';"); err == nil {
+		t.Error("deleted a course with children after undoing migration 10")
+	}
+
+	if err := s.MigrateUp(len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteCourse("20241000001"); err != nil {
+		t.Errorf("DeleteCourse after migrating back up: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Number of users with each CRN on their schedule, which is the popularity
+// signal used to rank courses
+func courseDemand() (map[string]int, error) {
+	rows, err := QueuedQuery("SELECT classes FROM users;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	demand := make(map[string]int)
+
+	for rows.Next() {
+		var courses string
+		err = rows.Scan(&courses)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, class := range parseClasses(courses) {
+			if class != "" {
+				demand[class]++
+			}
+		}
+	}
+
+	return demand, rows.Err()
+}
+
+// Number of users with each CRN on their schedule, as a measure of demand
+func CourseEnrollmentCounts() (map[string]int, error) {
+	return courseDemand()
+}
+
+// A course along with the number of users with it on their schedule
+type CourseCount struct {
+	Course courseload.Course
+	Count  int
+}
+
+// The limit courses on the most schedules, ties broken by CRN. Only those
+// courses are hydrated. CRNs on schedules whose course is gone are skipped
+func MostPopularCourses(limit int) ([]CourseCount, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	demand, err := courseDemand()
+	if err != nil {
+		return nil, err
+	}
+
+	crns := make([]string, 0, len(demand))
+	for crn := range demand {
+		crns = append(crns, crn)
+	}
+
+	sort.Slice(crns, func(i, j int) bool {
+		if demand[crns[i]] != demand[crns[j]] {
+			return demand[crns[i]] > demand[crns[j]]
+		}
+
+		return crns[i] < crns[j]
+	})
+
+	popular := make([]CourseCount, 0, min(limit, len(crns)))
+
+	// Usually the first batch fills the result, more are only loaded when
+	// some of its courses no longer exist
+	for start := 0; start < len(crns) && len(popular) < limit; start += limit {
+		batch := crns[start:min(start+limit, len(crns))]
+
+		courses, err := defaultStore.getCoursesByCRN(context.Background(), batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, course := range courses {
+			if len(popular) == limit {
+				break
+			}
+
+			popular = append(popular, CourseCount{Course: course, Count: demand[course.CRN]})
+		}
+	}
+
+	return popular, nil
+}
+
+// A subject's courses, most popular first, ties broken by CRN. The subject
+// may be an alias such as "cs", as SuggestSubject resolves
+func GetCoursesInSubjectByPopularity(subject string, limit int) ([]courseload.Course, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	subject, _ = SuggestSubject(subject)
+
+	demand, err := courseDemand()
+	if err != nil {
+		return nil, err
+	}
+
+	// Soft deleted courses are left out here rather than when hydrating, or
+	// they would use up the limit and shorten the page
+	crns, err := defaultStore.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE subject_code = ? AND archived_at IS NULL;", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(crns, func(i, j int) bool {
+		if demand[crns[i]] != demand[crns[j]] {
+			return demand[crns[i]] > demand[crns[j]]
+		}
+
+		return crns[i] < crns[j]
+	})
+
+	if len(crns) > limit {
+		crns = crns[:limit]
+	}
+
+	return defaultStore.getCoursesByCRN(context.Background(), crns)
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestGetCoursesInSubjectByPopularityResolvesSubject(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []struct{ crn, subject, number string }{
+		{"20241000001", "MATH", "425"},
+		{"20241000002", "MATH", "426"},
+		{"20241000003", "COMP", "400"},
+	} {
+		if err := InsertCourse(testCourse(course.crn, course.subject, course.number, "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		subject string
+		count   int
+	}{
+		{"MATH", 2},
+		{"math", 2},
+		{" Mathematics ", 2},
+		{"maths", 2},
+		{"comp", 1},
+		{"compsci", 1},
+		{"Computer Science", 1},
+		{"basketweaving", 0},
+	}
+
+	for _, test := range tests {
+		courses, err := GetCoursesInSubjectByPopularity(test.subject, 10)
+		if err != nil {
+			t.Errorf("%q: %v", test.subject, err)
+		} else if len(courses) != test.count {
+			t.Errorf("%q: got %d courses, want %d", test.subject, len(courses), test.count)
+		}
+	}
+}
+
+func TestMostPopularCourses(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, crn := range []string{"20241000001", "20241000002", "20241000003", "20241000004"} {
+		if err := InsertCourse(testCourse(crn, "COMP", "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for email, classes := range map[string][]string{
+		"a@unh.edu": {"20241000001", "20241000002", "20241000003"},
+		"b@unh.edu": {"20241000002", "20241000003"},
+		"c@unh.edu": {"20241000003", "20241000004"},
+		"d@unh.edu": {"20241000002"},
+		"e@unh.edu": nil,
+	} {
+		if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", email, status)
+		}
+
+		if err := SetUserClasses(email, classes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := CourseEnrollmentCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"20241000001": 1, "20241000002": 3, "20241000003": 3, "20241000004": 1}
+	if !maps.Equal(counts, want) {
+		t.Errorf("CourseEnrollmentCounts = %v, want %v", counts, want)
+	}
+
+	describe := func(limit int) []string {
+		t.Helper()
+
+		popular, err := MostPopularCourses(limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		described := make([]string, 0, len(popular))
+		for _, course := range popular {
+			described = append(described, fmt.Sprintf("%s %d", course.Course.CRN, course.Count))
+		}
+		return described
+	}
+
+	if got, want := describe(10), []string{"20241000002 3", "20241000003 3", "20241000001 1", "20241000004 1"}; !slices.Equal(got, want) {
+		t.Errorf("MostPopularCourses(10) = %v, want %v", got, want)
+	}
+
+	// Still on schedules, but gone from the courses table
+	if err = DeleteCourse("20241000003"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := describe(2), []string{"20241000002 3", "20241000001 1"}; !slices.Equal(got, want) {
+		t.Errorf("MostPopularCourses(2) after a delete = %v, want %v", got, want)
+	}
+
+	if _, err = MostPopularCourses(0); err == nil {
+		t.Error("MostPopularCourses with a limit of 0 succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"hacknhbackend.eparker.dev/util"
+)
+
+// Statements run often enough, mostly once per course during a bulk load,
+// that each Store prepares them the first time they are used and keeps
+// them until it is closed
+var preparedStatements = map[string]bool{
+	INSERT_COURSE_STATEMENT:             true,
+	UPSERT_INSTRUCTOR_PROFILE_STATEMENT: true,
+	INSERT_COURSE_INSTRUCTOR_STATEMENT:  true,
+	INSERT_MEETING_STATEMENT:            true,
+	SELECT_COUSE_STATEMENT:              true,
+	SELECT_INSTRUCTORS_STATEMENT:        true,
+	SELECT_MEETINGS_STATEMENT:           true,
+}
+
+// The prepared form of query, or nil when query isn't one of the
+// preparedStatements or fails to prepare, in which case callers run it as
+// plain text. A statement prepared on the pool is re-prepared per
+// connection by database/sql as needed
+func (s *Store) prepared(query string) *sql.Stmt {
+	if !preparedStatements[query] {
+		return nil
+	}
+
+	s.stmtsMu.Lock()
+	defer s.stmtsMu.Unlock()
+
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt
+	}
+
+	if s.db == nil {
+		return nil
+	}
+
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		util.Log.Error(fmt.Sprintf("Error preparing statement %s: %v", queryName(query), err))
+		return nil
+	}
+
+	if s.stmts == nil {
+		s.stmts = make(map[string]*sql.Stmt)
+	}
+
+	s.stmts[query] = stmt
+
+	return stmt
+}
+
+// Closes every statement prepared so far
+func (s *Store) closeStatements() {
+	s.stmtsMu.Lock()
+	defer s.stmtsMu.Unlock()
+
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+
+	s.stmts = nil
+}
+
+// Runs query on transaction, through its prepared statement when it has one
+func (s *Store) txExec(transaction *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	if stmt := s.prepared(query); stmt != nil {
+		return transaction.Stmt(stmt).Exec(args...)
+	}
+
+	return transaction.Exec(query, args...)
+}
+
+func (s *Store) txQueryRow(transaction *sql.Tx, query string, args ...interface{}) *sql.Row {
+	if stmt := s.prepared(query); stmt != nil {
+		return transaction.Stmt(stmt).QueryRow(args...)
+	}
+
+	return transaction.QueryRow(query, args...)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"sync"
 	"time"
@@ -26,16 +27,22 @@ var (
 
 func GetQueue() *DBQueue {
 	once.Do(func() {
-		queue = &DBQueue{
-			operations: make(chan Operation, util.Config.Database.QueueSize),
-			shutdown:   make(chan struct{}),
-		}
-		queue.start()
+		queue = newQueue(util.Config.Database.QueueSize)
 	})
 
 	return queue
 }
 
+func newQueue(size int) *DBQueue {
+	q := &DBQueue{
+		operations: make(chan Operation, size),
+		shutdown:   make(chan struct{}),
+	}
+	q.start()
+
+	return q
+}
+
 func (q *DBQueue) start() {
 	q.wg.Add(1)
 	go func() {
@@ -53,6 +60,16 @@ func (q *DBQueue) start() {
 }
 
 func (q *DBQueue) EnqueueOperation(operation func() error) error {
+	return q.EnqueueOperationContext(context.Background(), operation)
+}
+
+// Like EnqueueOperation but gives up waiting for a queue slot once ctx is
+// done. An operation that has started runs to completion
+func (q *DBQueue) EnqueueOperationContext(ctx context.Context, operation func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	resultChan := make(chan error, 1)
 	op := Operation{
 		execute: operation,
@@ -62,6 +79,8 @@ func (q *DBQueue) EnqueueOperation(operation func() error) error {
 	select {
 	case q.operations <- op:
 		return <-resultChan
+	case <-ctx.Done():
+		return ctx.Err()
 	case <-time.After(5 * time.Second):
 		return ErrorQueueTimeout
 	}
@@ -74,38 +93,162 @@ func (q *DBQueue) Shutdown() {
 }
 
 func QueuedExec(query string, args ...interface{}) error {
-	return GetQueue().EnqueueOperation(func() error {
-		_, err := db.Exec(query, args...)
-		return err
-	})
+	return QueuedExecContext(context.Background(), query, args...)
+}
+
+func QueuedExecContext(ctx context.Context, query string, args ...interface{}) error {
+	_, err := defaultStore.execContext(ctx, query, args...)
+	return err
+}
+
+func QueuedExecResult(query string, args ...interface{}) (sql.Result, error) {
+	return defaultStore.execContext(context.Background(), query, args...)
+}
+
+func QueuedExecResultContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return defaultStore.execContext(ctx, query, args...)
 }
 
 func QueuedQuery(query string, args ...interface{}) (*sql.Rows, error) {
+	return defaultStore.queryContext(context.Background(), query, args...)
+}
+
+func QueuedQueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return defaultStore.queryContext(ctx, query, args...)
+}
+
+func QueuedQueryRow(query string, args ...interface{}) *QueuedRow {
+	return defaultStore.queryRowContext(context.Background(), query, args...)
+}
+
+func QueuedQueryRowContext(ctx context.Context, query string, args ...interface{}) *QueuedRow {
+	return defaultStore.queryRowContext(ctx, query, args...)
+}
+
+func QueuedBegin() (*sql.Tx, error) {
+	return defaultStore.beginContext(context.Background())
+}
+
+func QueuedBeginContext(ctx context.Context) (*sql.Tx, error) {
+	return defaultStore.beginContext(ctx)
+}
+
+// Runs fn in a transaction on the default Store, so several writes succeed
+// or fail together
+func WithTx(fn func(tx *sql.Tx) error) error {
+	return defaultStore.WithTx(fn)
+}
+
+// A *sql.Row that can also carry an error from before the query ran, such
+// as the queue timing out or the database being closed
+type QueuedRow struct {
+	row *sql.Row
+	err error
+}
+
+func (r *QueuedRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.row.Scan(dest...)
+}
+
+func (s *Store) execContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := withRetryContext(ctx, func() error {
+		return s.getQueue().EnqueueOperationContext(ctx, func() error {
+			if s.db == nil {
+				return ErrDatabaseNotOpen
+			}
+
+			var err error
+			if stmt := s.prepared(query); stmt != nil {
+				result, err = stmt.ExecContext(ctx, args...)
+			} else {
+				result, err = s.db.ExecContext(ctx, query, args...)
+			}
+			return err
+		})
+	})
+	return result, err
+}
+
+func (s *Store) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	var rows *sql.Rows
-	err := GetQueue().EnqueueOperation(func() error {
+	err := s.getQueue().EnqueueOperationContext(ctx, func() error {
+		if s.db == nil {
+			return ErrDatabaseNotOpen
+		}
+
 		var err error
-		rows, err = db.Query(query, args...)
+		if stmt := s.prepared(query); stmt != nil {
+			rows, err = stmt.QueryContext(ctx, args...)
+		} else {
+			rows, err = s.db.QueryContext(ctx, query, args...)
+		}
 		return err
 	})
 	return rows, err
 }
 
-func QueuedQueryRow(query string, args ...interface{}) *sql.Row {
+func (s *Store) queryRowContext(ctx context.Context, query string, args ...interface{}) *QueuedRow {
 	var row *sql.Row
-	_ = GetQueue().EnqueueOperation(func() error {
-		row = db.QueryRow(query, args...)
+	err := s.getQueue().EnqueueOperationContext(ctx, func() error {
+		if s.db == nil {
+			return ErrDatabaseNotOpen
+		}
+
+		if stmt := s.prepared(query); stmt != nil {
+			row = stmt.QueryRowContext(ctx, args...)
+		} else {
+			row = s.db.QueryRowContext(ctx, query, args...)
+		}
 		return nil
 	})
-	return row
+
+	return &QueuedRow{row: row, err: err}
 }
 
-func QueuedBegin() (*sql.Tx, error) {
+// The transaction is rolled back if ctx is done before it commits
+func (s *Store) beginContext(ctx context.Context) (*sql.Tx, error) {
 	var tx *sql.Tx
-	err := GetQueue().EnqueueOperation(func() error {
+	err := s.getQueue().EnqueueOperationContext(ctx, func() error {
+		if s.db == nil {
+			return ErrDatabaseNotOpen
+		}
+
 		var err error
-		tx, err = db.Begin()
+		tx, err = s.db.BeginTx(ctx, nil)
 		return err
 	})
 
 	return tx, err
 }
+
+// Begins a transaction and runs fn in it, committing if fn returns nil and
+// rolling back otherwise. fn's error is returned as is. A panic in fn
+// rolls the transaction back before carrying on up the stack
+func (s *Store) WithTx(fn func(tx *sql.Tx) error) (err error) {
+	transaction, err := s.beginContext(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// fn may have written anything, courses included
+	defer s.cache.clear()
+
+	defer func() {
+		if r := recover(); r != nil {
+			transaction.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(transaction); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	return transaction.Commit()
+}
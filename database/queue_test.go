@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestWithTx(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loads the course into the cache, which the commit has to clear
+	if _, err := s.GetCourse("20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		var n int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM users;").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	insertUser := func(tx *sql.Tx, email string) error {
+		_, err := tx.Exec(INSERT_USER_STATEMENT, email, "A", "B", "hash", "[]")
+		return err
+	}
+
+	err := s.WithTx(func(tx *sql.Tx) error {
+		if err := insertUser(tx, "a@unh.edu"); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("UPDATE courses SET title = ? WHERE term_crn = ?;", "Data Structures and Algorithms", "20241000001")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 1 {
+		t.Errorf("got %d users after a commit, want 1", n)
+	}
+
+	if course, err := s.GetCourse("20241000001"); err != nil || course.Data.Title != "Data Structures and Algorithms" {
+		t.Errorf("GetCourse after the commit = %v, %v, want the new title", course, err)
+	}
+
+	failure := errors.New("step failed")
+
+	err = s.WithTx(func(tx *sql.Tx) error {
+		if err := insertUser(tx, "b@unh.edu"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Errorf("WithTx = %v, want fn's error as is", err)
+	}
+
+	if n := count(); n != 1 {
+		t.Errorf("got %d users after a failed step, want 1", n)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the panic to carry on up", r)
+			}
+		}()
+
+		s.WithTx(func(tx *sql.Tx) error {
+			if err := insertUser(tx, "c@unh.edu"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if n := count(); n != 1 {
+		t.Errorf("got %d users after a panic, want 1", n)
+	}
+
+	// The rolled back transactions gave their connection and lock back
+	if err = s.WithTx(func(tx *sql.Tx) error { return insertUser(tx, "d@unh.edu") }); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(); n != 2 {
+		t.Errorf("got %d users, want 2", n)
+	}
+}
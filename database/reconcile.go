@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// What ReconcileTerm did with each course
+type ReconcileStats struct {
+	// New courses along with soft deleted ones that came back
+	Added int
+
+	Updated, Removed, Unchanged int
+}
+
+// The YYYYTT term at the start of a CRN, matching the generated term column
+var termPattern = regexp.MustCompile(`^[0-9]{6}`)
+
+func courseTerm(term_crn string) string {
+	return termPattern.FindString(term_crn)
+}
+
+// Makes a term's courses match courses, the complete scrape of that term,
+// in a single transaction: new courses are inserted, changed ones updated
+// and ones missing from courses soft deleted. Courses DiffCourses finds
+// unchanged aren't written. The stored courses are read and diffed inside
+// that transaction. Courses of other terms are left alone, and a course in
+// courses from another term is an error
+func ReconcileTerm(term string, courses []courseload.Course) (ReconcileStats, error) {
+	return defaultStore.ReconcileTerm(term, courses)
+}
+
+func (s *Store) ReconcileTerm(term string, courses []courseload.Course) (ReconcileStats, error) {
+	var stats ReconcileStats
+
+	term = strings.TrimSpace(term)
+	if !termPattern.MatchString(term) || len(term) != 6 {
+		return stats, fmt.Errorf("term %q is not a YYYYTT code", term)
+	}
+
+	fresh := make(map[string]courseload.Course, len(courses))
+
+	for _, course := range courses {
+		if courseTerm(course.CRN) != term {
+			return stats, fmt.Errorf("course %s is not in term %s", course.CRN, term)
+		}
+
+		if _, ok := fresh[cacheKey(course.CRN)]; ok {
+			return stats, fmt.Errorf("course %s is listed twice", course.CRN)
+		}
+
+		fresh[cacheKey(course.CRN)] = course
+	}
+
+	defer s.cache.clear()
+
+	var added, removed, changed []string
+
+	err := withRetry(func() error {
+		transaction, err := s.beginContext(context.Background())
+		if err != nil {
+			return err
+		}
+
+		// A no-op write takes the write lock before anything is read, so
+		// no other writer can change the term between the diff and the
+		// writes it decides on
+		if _, err = transaction.Exec("UPDATE courses SET archived_at = archived_at WHERE term = ?;", term); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		current, err := coursesByKey(transaction.QueryContext, "SELECT term_crn FROM courses WHERE term = ? AND archived_at IS NULL;", term)
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		archivedCRNs, err := selectCRNsWith(context.Background(), transaction.QueryContext, "SELECT term_crn FROM courses WHERE term = ? AND archived_at IS NOT NULL;", term)
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		archived := make(map[string]bool, len(archivedCRNs))
+		for _, crn := range archivedCRNs {
+			archived[cacheKey(crn)] = true
+		}
+
+		added, removed, changed = diffCourses(current, courses)
+
+		now := time.Now().UTC()
+
+		for _, crn := range added {
+			course := fresh[cacheKey(crn)]
+
+			// A soft deleted course keeps its row, so it is brought back
+			// and given the scraped data rather than inserted again
+			if archived[cacheKey(crn)] {
+				_, err = transaction.Exec("UPDATE courses SET archived_at = NULL WHERE term_crn = ? COLLATE NOCASE;", crn)
+				if err == nil {
+					err = s.updateCourseTx(transaction, course)
+				}
+			} else {
+				err = s.insertCourseTx(transaction, course)
+			}
+
+			if err != nil {
+				transaction.Rollback()
+				return err
+			}
+		}
+
+		for _, crn := range changed {
+			if err = s.updateCourseTx(transaction, fresh[cacheKey(crn)]); err != nil {
+				transaction.Rollback()
+				return err
+			}
+		}
+
+		for _, crn := range removed {
+			if _, err = transaction.Exec("UPDATE courses SET archived_at = ? WHERE term_crn = ?;", now, crn); err != nil {
+				transaction.Rollback()
+				return fmt.Errorf("soft deleting course %s: %w", crn, err)
+			}
+		}
+
+		return transaction.Commit()
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	stats = ReconcileStats{
+		Added:     len(added),
+		Updated:   len(changed),
+		Removed:   len(removed),
+		Unchanged: len(fresh) - len(added) - len(changed),
+	}
+
+	util.Log.Status(fmt.Sprintf("Reconciled term %s: %d added, %d updated, %d removed, %d unchanged", term, stats.Added, stats.Updated, stats.Removed, stats.Unchanged))
+
+	return stats, nil
+}
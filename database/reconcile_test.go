@@ -0,0 +1,136 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestReconcileTerm(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Unchanged"),
+		testCourse("20241000002", "COMP", "405", "Old title"),
+		testCourse("20241000003", "COMP", "410", "Dropped"),
+		testCourse("20241000004", "COMP", "415", "Dropped earlier"),
+		testCourse("20245000001", "COMP", "400", "Other term"),
+	} {
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.SoftDeleteCourse("20241000004"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.ReconcileTerm("202410", []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Unchanged"),
+		testCourse("20241000002", "COMP", "405", "New title"),
+		testCourse("20241000004", "COMP", "415", "Back again"),
+		testCourse("20241000005", "COMP", "420", "Brand new"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (ReconcileStats{Added: 2, Updated: 1, Removed: 1, Unchanged: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	titles := map[string]string{
+		"20241000001": "Unchanged",
+		"20241000002": "New title",
+		"20241000004": "Back again",
+		"20241000005": "Brand new",
+		"20245000001": "Other term",
+	}
+
+	for crn, title := range titles {
+		course, err := s.GetCourse(crn)
+		if err != nil {
+			t.Errorf("GetCourse(%s): %v", crn, err)
+		} else if course.Data.Title != title {
+			t.Errorf("course %s title = %q, want %q", crn, course.Data.Title, title)
+		}
+	}
+
+	if _, err = s.GetCourse("20241000003"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("dropped course still active: %v", err)
+	}
+
+	if err = s.RestoreCourse("20241000003"); err != nil {
+		t.Errorf("dropped course wasn't soft deleted: %v", err)
+	}
+}
+
+func TestReconcileTermRejects(t *testing.T) {
+	s := newTestStore(t)
+
+	tests := []struct {
+		name    string
+		term    string
+		courses []courseload.Course
+	}{
+		{"bad term", "2024", nil},
+		{"other term", "202410", []courseload.Course{testCourse("20245000001", "COMP", "400", "Spring")}},
+		{"duplicate", "202410", []courseload.Course{testCourse("20241000001", "COMP", "400", "A"), testCourse("20241000001", "COMP", "400", "B")}},
+	}
+
+	for _, test := range tests {
+		if _, err := s.ReconcileTerm(test.term, test.courses); err == nil {
+			t.Errorf("%s: ReconcileTerm succeeded", test.name)
+		}
+	}
+
+	if count, _ := s.CountCourses(); count != 0 {
+		t.Errorf("rejected reconciles wrote %d courses", count)
+	}
+}
+
+func TestConcurrentReconcileTerm(t *testing.T) {
+	s := newTestStore(t)
+
+	courses := make([]courseload.Course, 20)
+	for i := range courses {
+		courses[i] = testCourse(fmt.Sprintf("202410%05d", i), "COMP", "400", "Course", testMeeting("MWF", "9:10am-10:00am"))
+	}
+
+	var wg sync.WaitGroup
+	stats := make([]ReconcileStats, 4)
+	errs := make([]error, len(stats))
+
+	for i := range stats {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats[i], errs[i] = s.ReconcileTerm("202410", courses)
+		}()
+	}
+
+	wg.Wait()
+
+	added, unchanged := 0, 0
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("ReconcileTerm %d: %v", i, err)
+		}
+
+		added += stats[i].Added
+		unchanged += stats[i].Unchanged
+	}
+
+	// Each run diffs what the runs before it committed, so only the first
+	// adds anything
+	if added != len(courses) || unchanged != len(courses)*(len(stats)-1) {
+		t.Errorf("added %d and left %d unchanged across the runs, want %d and %d", added, unchanged, len(courses), len(courses)*(len(stats)-1))
+	}
+
+	if count, err := s.CountCourses(); err != nil || count != len(courses) {
+		t.Errorf("CountCourses = %d, %v, want %d", count, err, len(courses))
+	}
+}
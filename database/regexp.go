@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"modernc.org/sqlite"
+)
+
+// Go's regexp runs in linear time so patterns can't backtrack
+// catastrophically, but long patterns still compile into large programs
+const maxRegexLength = 256
+
+var (
+	registerFunctions sync.Once
+	regexCache        = make(map[string]*regexp.Regexp)
+	regexCacheLock    sync.Mutex
+)
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxRegexLength {
+		return nil, fmt.Errorf("pattern longer than %d characters", maxRegexLength)
+	}
+
+	regexCacheLock.Lock()
+	defer regexCacheLock.Unlock()
+
+	if regex, ok := regexCache[pattern]; ok {
+		return regex, nil
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a handful of patterns are ever live at once
+	if len(regexCache) >= 64 {
+		regexCache = make(map[string]*regexp.Regexp)
+	}
+
+	regexCache[pattern] = regex
+
+	return regex, nil
+}
+
+// Backs sqlite's "X REGEXP Y" operator, which calls regexp(Y, X)
+func sqlRegexp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("regexp pattern must be text")
+	}
+
+	var value string
+
+	switch v := args[1].(type) {
+	case nil:
+		return false, nil
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	regex, err := compileRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return regex.MatchString(value), nil
+}
+
+func SearchCoursesByTitleRegex(pattern string) ([]courseload.Course, error) {
+	if _, err := compileRegex(pattern); err != nil {
+		return nil, err
+	}
+
+	crns, err := defaultStore.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE title REGEXP ? ORDER BY term_crn;", pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return defaultStore.getCoursesByCRN(context.Background(), crns)
+}
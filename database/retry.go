@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Runs fn, trying again with exponential backoff from baseDelay while it
+// fails because the database is busy or locked, up to maxRetries attempts.
+// Any other error is returned straight away
+func withRetry(fn func() error) error {
+	return withRetryContext(context.Background(), fn)
+}
+
+// withRetry that stops waiting between attempts once ctx is done,
+// returning ctx's error
+func withRetryContext(ctx context.Context, fn func() error) error {
+	var err error
+
+	for i := 0; i < maxRetries; i++ {
+		if err = fn(); err == nil || !isBusy(err) {
+			return err
+		}
+
+		if i < maxRetries-1 {
+			retryAttempts.Add(1)
+
+			timer := time.NewTimer(baseDelay << i)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	retryExhaustions.Add(1)
+
+	return err
+}
+
+// Whether err is SQLITE_BUSY or SQLITE_LOCKED, including their extended
+// codes such as SQLITE_BUSY_SNAPSHOT
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lock, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = lock.Exec("UPDATE courses SET archived_at = archived_at WHERE term = '202410';"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A connection that reports SQLITE_BUSY at once instead of waiting
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "PRAGMA busy_timeout = 0;"); err != nil {
+		t.Fatal(err)
+	}
+
+	var busy error
+	attempts := 0
+
+	err = withRetry(func() error {
+		attempts++
+
+		_, err := conn.ExecContext(ctx, "INSERT INTO scrape_runs (started_at) VALUES (?);", time.Now().UTC())
+		if attempts == 1 {
+			busy = err
+			lock.Rollback()
+		}
+
+		return err
+	})
+
+	if !isBusy(busy) {
+		t.Fatalf("first attempt error = %v, want SQLITE_BUSY", busy)
+	}
+
+	if err != nil || attempts != 2 {
+		t.Errorf("withRetry = %v after %d attempts, want success on the second", err, attempts)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	attempts = 0
+	start := time.Now()
+
+	err = withRetryContext(cancelled, func() error {
+		attempts++
+		return busy
+	})
+
+	if !errors.Is(err, context.Canceled) || attempts != 1 || time.Since(start) >= baseDelay {
+		t.Errorf("withRetryContext with a cancelled context = %v after %d attempts and %v, want context.Canceled without waiting", err, attempts, time.Since(start))
+	}
+
+	attempts = 0
+
+	if err = withRetry(func() error { attempts++; return ErrCourseNotFound }); !errors.Is(err, ErrCourseNotFound) || attempts != 1 {
+		t.Errorf("withRetry with a non busy error = %v after %d attempts, want it returned at once", err, attempts)
+	}
+}
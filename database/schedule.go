@@ -0,0 +1,567 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Courses whose earliest meeting starts at or after minMinutes past
+// midnight. A course with any meeting before the threshold is excluded, and
+// courses without a parseable meeting time are left out
+func GetCoursesStartingAfter(minMinutes int) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesStartingAfter(minMinutes)
+}
+
+func (s *Store) GetCoursesStartingAfter(minMinutes int) ([]courseload.Course, error) {
+	rows, err := s.queryContext(context.Background(), "SELECT term_crn, time FROM meetings;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	earliest := make(map[string]int)
+
+	for rows.Next() {
+		var term_crn, meetingTime string
+		err = rows.Scan(&term_crn, &meetingTime)
+		if err != nil {
+			return nil, err
+		}
+
+		start, _, err := courseload.ParseMeetingTime(meetingTime)
+		if err != nil {
+			continue
+		}
+
+		minutes := start.Hour()*60 + start.Minute()
+
+		if current, ok := earliest[term_crn]; !ok || minutes < current {
+			earliest[term_crn] = minutes
+		}
+	}
+
+	crns := make([]string, 0)
+
+	for term_crn, minutes := range earliest {
+		if minutes >= minMinutes {
+			crns = append(crns, term_crn)
+		}
+	}
+
+	sort.Strings(crns)
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
+
+// The day code of a single day, such as "R" for Thursday
+func dayCode(day time.Weekday) string {
+	return courseload.Weekdays(1 << day).String()
+}
+
+// Per day code, the number of meetings active during each hour of the day.
+// A meeting counts toward every hour it overlaps, so 9:30 - 10:45 counts
+// toward both 9 and 10. Meetings with unknown day letters are skipped
+func MeetingDensity() (map[string]map[int]int, error) {
+	return defaultStore.MeetingDensity()
+}
+
+func (s *Store) MeetingDensity() (map[string]map[int]int, error) {
+	rows, err := s.queryContext(context.Background(), "SELECT meetings.days, meetings.time FROM meetings JOIN courses ON courses.term_crn = meetings.term_crn WHERE courses.archived_at IS NULL;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	density := make(map[string]map[int]int)
+
+	for rows.Next() {
+		var days, meetingTime string
+		err = rows.Scan(&days, &meetingTime)
+		if err != nil {
+			return nil, err
+		}
+
+		weekdays, err := courseload.ParseWeekdays(days)
+		if err != nil {
+			continue
+		}
+
+		start, end, err := courseload.ParseMeetingTime(meetingTime)
+		if err != nil {
+			continue
+		}
+
+		startMinutes := start.Hour()*60 + start.Minute()
+		endMinutes := end.Hour()*60 + end.Minute()
+
+		for _, weekday := range weekdays.Days() {
+			day := dayCode(weekday)
+			if density[day] == nil {
+				density[day] = make(map[int]int)
+			}
+
+			for hour := startMinutes / 60; hour*60 < endMinutes; hour++ {
+				density[day][hour]++
+			}
+		}
+	}
+
+	return density, rows.Err()
+}
+
+// Courses whose meeting blocks last between minMinutes and maxMinutes
+// inclusive. Every parseable meeting of a course has to fall in the range,
+// so a course pairing a 50 minute lecture with a 3 hour lab matches neither
+// a short nor a long range. Meetings without a parseable time are ignored,
+// and courses with none are left out
+func GetCoursesByMeetingDuration(minMinutes, maxMinutes int) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesByMeetingDuration(minMinutes, maxMinutes)
+}
+
+func (s *Store) GetCoursesByMeetingDuration(minMinutes, maxMinutes int) ([]courseload.Course, error) {
+	if minMinutes < 0 || maxMinutes < minMinutes {
+		return nil, fmt.Errorf("invalid duration range %d-%d", minMinutes, maxMinutes)
+	}
+
+	rows, err := s.queryContext(context.Background(), "SELECT term_crn, time FROM meetings;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	matches := make(map[string]bool)
+
+	for rows.Next() {
+		var term_crn, meetingTime string
+		err = rows.Scan(&term_crn, &meetingTime)
+		if err != nil {
+			return nil, err
+		}
+
+		start, end, err := courseload.ParseMeetingTime(meetingTime)
+		if err != nil {
+			continue
+		}
+
+		minutes := int(end.Sub(start).Minutes())
+		inRange := minutes >= minMinutes && minutes <= maxMinutes
+
+		if matched, seen := matches[term_crn]; !seen || matched {
+			matches[term_crn] = inRange
+		}
+	}
+
+	crns := make([]string, 0)
+
+	for term_crn, matched := range matches {
+		if matched {
+			crns = append(crns, term_crn)
+		}
+	}
+
+	sort.Strings(crns)
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
+
+// Day names accepted by GetCoursesByDayAndTime besides the day codes
+var dayNames = map[string]string{
+	"mon": "M", "monday": "M",
+	"tu": "T", "tue": "T", "tues": "T", "tuesday": "T",
+	"wed": "W", "wednesday": "W",
+	"th": "R", "thu": "R", "thur": "R", "thurs": "R", "thursday": "R",
+	"fri": "F", "friday": "F",
+	"sat": "S", "saturday": "S",
+	"sun": "U", "sunday": "U",
+}
+
+// Courses meeting on day whose meetings that day all fall between start
+// and end. The day is a code such as "T" or a name such as "Tuesday", and
+// start and end are 24 hour times such as "09:00" or "1300". TBA meetings
+// are ignored, so a course meeting only TBA on day doesn't match
+func GetCoursesByDayAndTime(day string, startHHMM, endHHMM string) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesByDayAndTime(day, startHHMM, endHHMM)
+}
+
+func (s *Store) GetCoursesByDayAndTime(day string, startHHMM, endHHMM string) ([]courseload.Course, error) {
+	code := strings.ToUpper(strings.TrimSpace(day))
+	if name, ok := dayNames[strings.ToLower(code)]; ok {
+		code = name
+	}
+
+	wanted, err := courseload.ParseWeekdays(code)
+	if err != nil || len(code) != 1 {
+		return nil, fmt.Errorf("unknown day %q", day)
+	}
+
+	windowStart, err := parseHHMM(startHHMM)
+	if err != nil {
+		return nil, err
+	}
+
+	windowEnd, err := parseHHMM(endHHMM)
+	if err != nil {
+		return nil, err
+	}
+
+	if windowEnd.Before(windowStart) {
+		return nil, fmt.Errorf("invalid time range %s-%s", startHHMM, endHHMM)
+	}
+
+	rows, err := s.queryContext(context.Background(), "SELECT term_crn, days, time FROM meetings;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	matches := make(map[string]bool)
+
+	for rows.Next() {
+		var term_crn, days, meetingTime string
+		err = rows.Scan(&term_crn, &days, &meetingTime)
+		if err != nil {
+			return nil, err
+		}
+
+		if weekdays, err := courseload.ParseWeekdays(days); err != nil || weekdays&wanted == 0 {
+			continue
+		}
+
+		start, end, err := courseload.ParseMeetingTime(meetingTime)
+		if err != nil {
+			continue
+		}
+
+		inRange := !start.Before(windowStart) && !end.After(windowEnd)
+
+		if matched, seen := matches[term_crn]; !seen || matched {
+			matches[term_crn] = inRange
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	crns := make([]string, 0)
+
+	for term_crn, matched := range matches {
+		if matched {
+			crns = append(crns, term_crn)
+		}
+	}
+
+	sort.Strings(crns)
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
+
+// Parses "09:00" or "0900" onto the zero date used by ParseMeetingTime
+func parseHHMM(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	for _, layout := range []string{"15:04", "1504"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", s)
+}
+
+// Whether two meetings share a day and overlap in time. Meetings without a
+// parseable time, such as TBA, never conflict
+func meetingsConflict(a, b courseload.Meeting) bool {
+	_, _, _, ok := meetingOverlap(a, b)
+	return ok
+}
+
+// The days two meetings share and the window they overlap on those days
+func meetingOverlap(a, b courseload.Meeting) (days []string, start, end time.Time, ok bool) {
+	aStart, aEnd, err := courseload.ParseMeetingTime(a.Time)
+	if err != nil {
+		return nil, start, end, false
+	}
+
+	bStart, bEnd, err := courseload.ParseMeetingTime(b.Time)
+	if err != nil {
+		return nil, start, end, false
+	}
+
+	if !aStart.Before(bEnd) || !bStart.Before(aEnd) {
+		return nil, start, end, false
+	}
+
+	aDays, err := courseload.ParseWeekdays(a.Days)
+	if err != nil {
+		return nil, start, end, false
+	}
+
+	bDays, err := courseload.ParseWeekdays(b.Days)
+	if err != nil {
+		return nil, start, end, false
+	}
+
+	for _, day := range (aDays & bDays).Days() {
+		days = append(days, dayCode(day))
+	}
+
+	if len(days) == 0 {
+		return nil, start, end, false
+	}
+
+	start, end = aStart, aEnd
+	if bStart.After(start) {
+		start = bStart
+	}
+
+	if bEnd.Before(end) {
+		end = bEnd
+	}
+
+	return days, start, end, true
+}
+
+// Two courses whose meetings overlap on Days between Start and End
+type Conflict struct {
+	CRNs       [2]string
+	Days       []string
+	Start, End time.Time
+}
+
+// crns in order without repeats, comparing them without regard to case
+func uniqueCRNs(crns []string) []string {
+	unique := make([]string, 0, len(crns))
+	seen := make(map[string]bool, len(crns))
+
+	for _, crn := range crns {
+		if !seen[strings.ToUpper(crn)] {
+			seen[strings.ToUpper(crn)] = true
+			unique = append(unique, crn)
+		}
+	}
+
+	return unique
+}
+
+// Every pair of meetings among the given courses that overlap. Meetings
+// without a set time, such as TBA, are skipped, and a CRN listed more than
+// once is only counted once
+func DetectConflicts(crns []string) ([]Conflict, error) {
+	return defaultStore.DetectConflicts(crns)
+}
+
+func (s *Store) DetectConflicts(crns []string) ([]Conflict, error) {
+	crns = uniqueCRNs(crns)
+
+	courses, err := s.getCoursesByCRN(context.Background(), crns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(courses) != len(crns) {
+		found := make(map[string]bool, len(courses))
+		for _, course := range courses {
+			found[strings.ToUpper(course.CRN)] = true
+		}
+
+		for _, crn := range crns {
+			if !found[strings.ToUpper(crn)] {
+				return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, crn)
+			}
+		}
+	}
+
+	return findConflicts(courses), nil
+}
+
+func findConflicts(courses []courseload.Course) []Conflict {
+	conflicts := make([]Conflict, 0)
+
+	for i := range courses {
+		for j := i + 1; j < len(courses); j++ {
+			for _, a := range courses[i].Data.Meetings {
+				for _, b := range courses[j].Data.Meetings {
+					if days, start, end, ok := meetingOverlap(a, b); ok {
+						conflicts = append(conflicts, Conflict{
+							CRNs:  [2]string{courses[i].CRN, courses[j].CRN},
+							Days:  days,
+							Start: start,
+							End:   end,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Everything wrong with a schedule at once. A schedule with no Unknown,
+// Full or Conflicts is valid
+type ScheduleReport struct {
+	// CRNs without a course
+	Unknown []string
+
+	// CRNs of sections with no seats available. Sections whose seats
+	// aren't known are never full
+	Full []string
+
+	Conflicts []Conflict
+
+	// Total credit hours of the courses that exist
+	Credits float64
+}
+
+func (r ScheduleReport) Valid() bool {
+	return len(r.Unknown) == 0 && len(r.Full) == 0 && len(r.Conflicts) == 0
+}
+
+// Checks a schedule before it is saved, reporting every unknown CRN, full
+// section and time conflict rather than stopping at the first. A CRN
+// listed more than once is only checked once
+func ValidateSchedule(crns []string) (ScheduleReport, error) {
+	return defaultStore.ValidateSchedule(crns)
+}
+
+func (s *Store) ValidateSchedule(crns []string) (ScheduleReport, error) {
+	report := ScheduleReport{
+		Unknown:   make([]string, 0),
+		Full:      make([]string, 0),
+		Conflicts: make([]Conflict, 0),
+	}
+
+	unique := uniqueCRNs(crns)
+
+	courses, err := s.getCoursesByCRN(context.Background(), unique)
+	if err != nil {
+		return report, err
+	}
+
+	found := make(map[string]bool, len(courses))
+
+	for _, course := range courses {
+		found[strings.ToUpper(course.CRN)] = true
+
+		if course.Data.SeatsTotal > 0 && course.Data.SeatsAvailable <= 0 {
+			report.Full = append(report.Full, course.CRN)
+		}
+
+		report.Credits += course.Data.Credits
+	}
+
+	for _, crn := range unique {
+		if !found[strings.ToUpper(crn)] {
+			report.Unknown = append(report.Unknown, crn)
+		}
+	}
+
+	report.Conflicts = findConflicts(courses)
+
+	return report, nil
+}
+
+// Courses in a subject that fit around the user's current schedule without
+// a time conflict, excluding courses the user already has
+func SuggestNonConflictingCourses(email string, subject string) ([]courseload.Course, error) {
+	return defaultStore.SuggestNonConflictingCourses(email, subject)
+}
+
+func (s *Store) SuggestNonConflictingCourses(email string, subject string) ([]courseload.Course, error) {
+	var classes string
+
+	err := s.queryRowContext(context.Background(), "SELECT classes FROM users WHERE email = ?;", email).Scan(&classes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	} else if err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.getCoursesByCRN(context.Background(), parseClasses(classes))
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(scheduled))
+	busy := make([]courseload.Meeting, 0)
+
+	for _, course := range scheduled {
+		taken[course.CRN] = true
+		busy = append(busy, course.Data.Meetings...)
+	}
+
+	candidates, err := s.QueryCourse("subject_code", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]courseload.Course, 0)
+
+	for _, candidate := range candidates {
+		if taken[candidate.CRN] {
+			continue
+		}
+
+		fits := true
+
+		for _, meeting := range candidate.Data.Meetings {
+			for _, other := range busy {
+				if meetingsConflict(meeting, other) {
+					fits = false
+				}
+			}
+		}
+
+		if fits {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// How many of a subject's meetings start in each hour of the day
+func SubjectTimeDistribution(subject string) (map[int]int, error) {
+	return defaultStore.SubjectTimeDistribution(subject)
+}
+
+func (s *Store) SubjectTimeDistribution(subject string) (map[int]int, error) {
+	subject, _ = SuggestSubject(subject)
+
+	rows, err := s.queryContext(context.Background(), "SELECT meetings.time FROM meetings JOIN courses ON courses.term_crn = meetings.term_crn WHERE courses.subject_code = ? AND courses.archived_at IS NULL;", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	distribution := make(map[int]int)
+
+	for rows.Next() {
+		var meetingTime string
+		if err = rows.Scan(&meetingTime); err != nil {
+			return nil, err
+		}
+
+		start, _, err := courseload.ParseMeetingTime(meetingTime)
+		if err != nil {
+			continue
+		}
+
+		distribution[start.Hour()]++
+	}
+
+	return distribution, rows.Err()
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestMeetingOverlapDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		days    []string
+		overlap bool
+	}{
+		{"shared days", "MWF", "MW", []string{"M", "W"}, true},
+		{"lowercase", "tr", "R", []string{"R"}, true},
+		{"no shared day", "MWF", "TR", nil, false},
+		{"unknown letter", "MXF", "MWF", nil, false},
+		{"no days", "", "MWF", nil, false},
+	}
+
+	for _, test := range tests {
+		days, _, _, ok := meetingOverlap(testMeeting(test.a, "9:10am-10:00am"), testMeeting(test.b, "9:30am-10:45am"))
+		if ok != test.overlap || !slices.Equal(days, test.days) {
+			t.Errorf("%s: days = %v, %v, want %v, %v", test.name, days, ok, test.days, test.overlap)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	useTestDatabase(t)
+
+	open := testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))
+	open.Data.SeatsTotal, open.Data.SeatsAvailable, open.Data.Credits = 30, 5, 4
+
+	overlapping := testCourse("20241000002", "COMP", "405", "Software Engineering", testMeeting("MW", "9:30am-10:45am"))
+	overlapping.Data.SeatsTotal, overlapping.Data.SeatsAvailable, overlapping.Data.Credits = 30, 2, 4
+
+	full := testCourse("20241000003", "MATH", "425", "Calculus", testMeeting("TR", "9:10am-10:00am"))
+	full.Data.SeatsTotal, full.Data.Credits = 25, 4
+
+	// The feed carries no seats, so scraped sections have none
+	unknownSeats := testCourse("20241000004", "ENGL", "401", "Writing", testMeeting("TR", "2:10pm-3:30pm"))
+
+	for _, course := range []courseload.Course{open, overlapping, full, unknownSeats} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		crns      []string
+		unknown   []string
+		full      []string
+		conflicts int
+		credits   float64
+	}{
+		{"clean", []string{"20241000001", "20241000004"}, []string{}, []string{}, 0, 4},
+		{"conflict", []string{"20241000001", "20241000002"}, []string{}, []string{}, 1, 8},
+		{"full", []string{"20241000003", "20241000001"}, []string{}, []string{"20241000003"}, 0, 8},
+		{"unknown", []string{"20241000001", "20241099999", "20241099999"}, []string{"20241099999"}, []string{}, 0, 4},
+		{"repeated", []string{"20241000001", "20241000001"}, []string{}, []string{}, 0, 4},
+	}
+
+	for _, test := range tests {
+		report, err := ValidateSchedule(test.crns)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !slices.Equal(report.Unknown, test.unknown) || !slices.Equal(report.Full, test.full) || len(report.Conflicts) != test.conflicts || report.Credits != test.credits {
+			t.Errorf("%s: report = %+v, want unknown %v, full %v, %d conflicts, %v credits", test.name, report, test.unknown, test.full, test.conflicts, test.credits)
+		}
+
+		if valid := len(test.unknown) == 0 && len(test.full) == 0 && test.conflicts == 0; report.Valid() != valid {
+			t.Errorf("%s: Valid() = %v, want %v", test.name, report.Valid(), valid)
+		}
+	}
+}
+
+func TestDetectConflicts(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am")),
+		testCourse("20241000002", "COMP", "405", "Software Engineering", testMeeting("MW", "9:30am-10:45am")),
+		testCourse("20241000003", "MATH", "425", "Calculus", testMeeting("TR", "9:10am-10:00am")),
+		testCourse("20241000004", "ENGL", "401", "Writing", testMeeting("TBA", "TBA")),
+		testCourse("2024100000A", "PHYS", "407", "Physics", testMeeting("F", "1:10pm-2:00pm")),
+	} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		crns []string
+		days [][]string
+	}{
+		{"clean", []string{"20241000001", "20241000004", "2024100000A"}, nil},
+		{"overlapping", []string{"20241000001", "20241000002"}, [][]string{{"M", "W"}}},
+		{"same time different day", []string{"20241000001", "20241000003"}, nil},
+		{"repeated", []string{"20241000001", "20241000001"}, nil},
+		{"repeated in another case", []string{"2024100000A", "2024100000a"}, nil},
+	}
+
+	for _, test := range tests {
+		conflicts, err := DetectConflicts(test.crns)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if len(conflicts) != len(test.days) {
+			t.Errorf("%s: conflicts = %+v, want %d", test.name, conflicts, len(test.days))
+			continue
+		}
+
+		for i, conflict := range conflicts {
+			if !slices.Equal(conflict.Days, test.days[i]) || conflict.Start.Format("15:04") != "09:30" || conflict.End.Format("15:04") != "10:00" {
+				t.Errorf("%s: conflict = %+v, want %v from 09:30 to 10:00", test.name, conflict, test.days[i])
+			}
+		}
+	}
+
+	if _, err := DetectConflicts([]string{"20241000001", "20241099999"}); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("missing course error = %v, want ErrCourseNotFound", err)
+	}
+}
+
+func TestGetCoursesByDayAndTime(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, course := range []courseload.Course{
+		testCourse("20241000001", "COMP", "400", "MWF morning", testMeeting("MWF", "9:10am-10:00am")),
+		testCourse("20241000002", "COMP", "401", "TR morning", testMeeting("TR", "9:40am-10:55am")),
+		testCourse("20241000003", "COMP", "402", "MWF with a lab", testMeeting("MWF", "9:10am-10:00am"), testMeeting("W", "4:10pm-5:00pm")),
+		testCourse("20241000004", "COMP", "403", "Arranged", testMeeting("TBA", "TBA")),
+		testCourse("20241000005", "COMP", "404", "TR afternoon", testMeeting("TR", "2:10pm-3:30pm")),
+	} {
+		if err := s.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		day, start, end string
+		want            []string
+	}{
+		{"M", "08:00", "12:00", []string{"20241000001", "20241000003"}},
+		{"Wednesday", "08:00", "12:00", []string{"20241000001"}},
+		{"W", "08:00", "17:00", []string{"20241000001", "20241000003"}},
+		{"F", "09:10", "10:00", []string{"20241000001", "20241000003"}},
+		{"T", "09:00", "11:00", []string{"20241000002"}},
+		{"thu", "0900", "1600", []string{"20241000002", "20241000005"}},
+		{"R", "09:30", "10:00", nil},
+		{"S", "00:00", "23:59", nil},
+	} {
+		courses, err := s.GetCoursesByDayAndTime(test.day, test.start, test.end)
+		if err != nil {
+			t.Fatalf("GetCoursesByDayAndTime(%q, %q, %q): %v", test.day, test.start, test.end, err)
+		}
+
+		var crns []string
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("GetCoursesByDayAndTime(%q, %q, %q) = %v, want %v", test.day, test.start, test.end, crns, test.want)
+		}
+	}
+
+	for _, bad := range [][3]string{{"X", "08:00", "12:00"}, {"MW", "08:00", "12:00"}, {"M", "9am", "12:00"}, {"M", "12:00", "08:00"}} {
+		if _, err := s.GetCoursesByDayAndTime(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("GetCoursesByDayAndTime(%q, %q, %q) succeeded", bad[0], bad[1], bad[2])
+		}
+	}
+}
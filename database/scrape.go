@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ScrapeRunStats struct {
+	Added, Updated, Removed int
+
+	// Set when the run failed
+	Error error
+}
+
+type ScrapeRun struct {
+	ID                      int64
+	StartedAt               time.Time
+	FinishedAt              *time.Time
+	Added, Updated, Removed int
+	Success                 bool
+	Error                   string
+}
+
+// Records the start of an import, returning the run ID to finish it with
+func StartScrapeRun() (int64, error) {
+	result, err := QueuedExecResult("INSERT INTO scrape_runs (started_at) VALUES (?);", time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func FinishScrapeRun(id int64, stats ScrapeRunStats) error {
+	var message string
+
+	if stats.Error != nil {
+		message = stats.Error.Error()
+	}
+
+	result, err := QueuedExecResult("UPDATE scrape_runs SET finished_at = ?, added = ?, updated = ?, removed = ?, success = ?, error = ? WHERE id = ?;",
+		time.Now().UTC(), stats.Added, stats.Updated, stats.Removed, stats.Error == nil, message, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("scrape run %d does not exist", id)
+	}
+
+	return nil
+}
+
+// The most recent runs, newest first
+func GetScrapeRuns(limit int) ([]ScrapeRun, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	rows, err := QueuedQuery("SELECT id, started_at, finished_at, added, updated, removed, success, error FROM scrape_runs ORDER BY id DESC LIMIT ?;", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	runs := make([]ScrapeRun, 0)
+
+	for rows.Next() {
+		var run ScrapeRun
+		var finishedAt sql.NullTime
+
+		err = rows.Scan(&run.ID, &run.StartedAt, &finishedAt, &run.Added, &run.Updated, &run.Removed, &run.Success, &run.Error)
+		if err != nil {
+			return nil, err
+		}
+
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// How long ago the last successful scrape run finished. Wraps
+// ErrNoScrapeRun when no run has succeeded yet
+func StalestCourseAge() (time.Duration, error) {
+	return defaultStore.StalestCourseAge()
+}
+
+func (s *Store) StalestCourseAge() (time.Duration, error) {
+	var finished_at time.Time
+
+	err := s.queryRowContext(context.Background(), "SELECT finished_at FROM scrape_runs WHERE success = 1 AND finished_at IS NOT NULL ORDER BY id DESC LIMIT 1;").Scan(&finished_at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: no scrape run has succeeded", ErrNoScrapeRun)
+	} else if err != nil {
+		return 0, err
+	}
+
+	return time.Since(finished_at), nil
+}
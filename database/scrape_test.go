@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStalestCourseAge(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, err := s.StalestCourseAge(); !errors.Is(err, ErrNoScrapeRun) {
+		t.Errorf("no runs error = %v, want ErrNoScrapeRun", err)
+	}
+
+	const run = "INSERT INTO scrape_runs (started_at, finished_at, success) VALUES (?, ?, ?);"
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		min, max time.Duration
+	}{
+		{"failed run", run, []interface{}{now, now, false}, -1, -1},
+		{"three days old", run, []interface{}{now.Add(-73 * time.Hour), now.Add(-72 * time.Hour), true}, 71 * time.Hour, 73 * time.Hour},
+		{"newer failed run", run, []interface{}{now, now, false}, 71 * time.Hour, 73 * time.Hour},
+		{"unfinished run", "INSERT INTO scrape_runs (started_at) VALUES (?);", []interface{}{now}, 71 * time.Hour, 73 * time.Hour},
+		{"course changed", "INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description, updated_at) VALUES ('20241012346', 'Calculus', 'MATH', '425', '01', '', ?);", []interface{}{now}, 71 * time.Hour, 73 * time.Hour},
+		{"newer run", run, []interface{}{now.Add(-2 * time.Hour), now.Add(-time.Hour), true}, 59 * time.Minute, 61 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if _, err := s.db.Exec(test.query, test.args...); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		age, err := s.StalestCourseAge()
+		if test.min < 0 {
+			if !errors.Is(err, ErrNoScrapeRun) {
+				t.Errorf("%s: error = %v, want ErrNoScrapeRun", test.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if age < test.min || age > test.max {
+			t.Errorf("%s: age = %v, want between %v and %v", test.name, age, test.min, test.max)
+		}
+	}
+}
+
+func TestCourseTimestampsBackfilled(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.MigrateDown(11); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.db.Exec("INSERT INTO courses (term_crn, title, subject_code, course_number, section_number, description) VALUES ('20241012345', 'Software Engineering', 'COMP', '405', '01', '');"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MigrateUp(len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+
+	course, err := s.GetCourse("20241012345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if course.CreatedAt == nil || course.UpdatedAt == nil || time.Since(*course.UpdatedAt) > time.Minute || time.Since(*course.UpdatedAt) < 0 {
+		t.Errorf("backfilled timestamps = %v, %v, want about now", course.CreatedAt, course.UpdatedAt)
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Courses matching every word of query in their title, description or
+// subject, most relevant first. Title matches outweigh subject matches,
+// which outweigh description matches, and words match their stems so
+// "learning" finds "learn"
+func SearchCourses(query string) ([]courseload.Course, error) {
+	match := ftsMatchExpression(query)
+	if match == "" {
+		return make([]courseload.Course, 0), nil
+	}
+
+	crns, err := defaultStore.selectCRNs(context.Background(), "SELECT term_crn FROM courses_fts WHERE courses_fts MATCH ? ORDER BY rank;", match)
+	if err != nil {
+		return nil, err
+	}
+
+	return defaultStore.getCoursesByCRN(context.Background(), crns)
+}
+
+// Quotes each word of user input so FTS5 treats it as a plain term rather
+// than query syntax, e.g. `intro AND "ml` becomes `"intro" "and" "ml"`
+func ftsMatchExpression(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for i, word := range words {
+		words[i] = `"` + strings.ToLower(word) + `"`
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchCourses(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []struct {
+		crn, subject, title, description string
+	}{
+		{"20241000001", "COMP", "Machine Learning", "Models that improve with data."},
+		{"20241000002", "MATH", "Statistics", "Probability with applications to learning and gradient descent."},
+		{"20241000003", "ENGL", "Poetry", "Reading and writing verse."},
+	} {
+		c := testCourse(course.crn, course.subject, "400", course.title)
+		c.Data.Description = course.description
+
+		if err := InsertCourse(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		{"gradient", []string{"20241000002"}},
+		{"learning", []string{"20241000001", "20241000002"}},
+		{"learn", []string{"20241000001", "20241000002"}},
+		{"math", []string{"20241000002"}},
+		{"learning poetry", nil},
+		{`"verse`, []string{"20241000003"}},
+		{"verse OR", nil},
+		{"  ", nil},
+	} {
+		courses, err := SearchCourses(test.query)
+		if err != nil {
+			t.Fatalf("SearchCourses(%q): %v", test.query, err)
+		}
+
+		var crns []string
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("SearchCourses(%q) = %v, want %v", test.query, crns, test.want)
+		}
+	}
+}
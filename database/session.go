@@ -0,0 +1,65 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const sessionLifetime = 7 * 24 * time.Hour
+
+func CreateSession(email string) (token string, err error) {
+	bytes := make([]byte, 32)
+
+	if _, err = rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	token = fmt.Sprintf("%x", bytes)
+	now := time.Now().UTC()
+
+	err = QueuedExec("INSERT INTO sessions (token, email, created_at, expires_at) VALUES (?, ?, ?, ?);", token, email, now, now.Add(sessionLifetime))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// The email a session belongs to. Expired sessions are removed and report
+// ErrSessionExpired
+func GetSession(token string) (email string, err error) {
+	var expiresAt time.Time
+
+	err = QueuedQueryRow("SELECT email, expires_at FROM sessions WHERE token = ?;", token).Scan(&email, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrSessionNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	if !expiresAt.After(time.Now()) {
+		DeleteSession(token)
+		return "", ErrSessionExpired
+	}
+
+	return email, nil
+}
+
+func DeleteSession(token string) error {
+	return QueuedExec("DELETE FROM sessions WHERE token = ?;", token)
+}
+
+// Removes every expired session, returning how many were removed
+func PurgeExpiredSessions() (int, error) {
+	result, err := QueuedExecResult("DELETE FROM sessions WHERE expires_at <= ?;", time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	return int(affected), err
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Other courses in the same subject and hundreds level as the given one,
+// closest course number first. Other sections of the same course aren't
+// counted as similar courses
+func FindSimilarCourses(crn string, limit int) ([]courseload.Course, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	seed, err := GetCourse(crn)
+	if err != nil {
+		return nil, err
+	}
+
+	// CAST takes the leading digits, so "415W" is 415
+	crns, err := defaultStore.selectCRNs(context.Background(), `SELECT term_crn FROM courses
+WHERE subject_code = ?1 COLLATE NOCASE AND course_number <> ?2 COLLATE NOCASE AND archived_at IS NULL
+AND CAST(course_number AS INTEGER) / 100 = CAST(?2 AS INTEGER) / 100
+ORDER BY ABS(CAST(course_number AS INTEGER) - CAST(?2 AS INTEGER)), course_number, term_crn
+LIMIT ?3;`, seed.Data.Subject, seed.Data.Number, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return defaultStore.getCoursesByCRN(context.Background(), crns)
+}
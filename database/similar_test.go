@@ -0,0 +1,59 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFindSimilarCourses(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, course := range []struct {
+		crn, subject, number string
+	}{
+		{"20241000001", "COMP", "415"},
+		{"20241000002", "COMP", "415"},
+		{"20241000003", "COMP", "417"},
+		{"20241000004", "COMP", "416"},
+		{"20241000005", "COMP", "414"},
+		{"20241000006", "COMP", "499"},
+		{"20241000007", "COMP", "400"},
+		{"20241000008", "COMP", "520"},
+		{"20241000009", "MATH", "416"},
+	} {
+		if err := InsertCourse(testCourse(course.crn, course.subject, course.number, "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"20241000005", "20241000004", "20241000003", "20241000007", "20241000006"}},
+		{2, []string{"20241000005", "20241000004"}},
+	} {
+		courses, err := FindSimilarCourses("20241000001", test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var crns []string
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("FindSimilarCourses with limit %d = %v, want %v", test.limit, crns, test.want)
+		}
+	}
+
+	if _, err := FindSimilarCourses("20241099999", 10); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("FindSimilarCourses for a missing course = %v, want ErrCourseNotFound", err)
+	}
+
+	if _, err := FindSimilarCourses("20241000001", 0); err == nil {
+		t.Error("FindSimilarCourses with a limit of 0 succeeded")
+	}
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+const defaultSlowQueryThreshold = 200 * time.Millisecond
+
+var slowQueryThreshold atomic.Int64
+
+// The sqlite driver with every statement timed by logQuery, registered
+// under its own name so the plain driver stays available
+const timedDriverName = "sqlite-timed"
+
+func init() {
+	slowQueryThreshold.Store(int64(defaultSlowQueryThreshold))
+
+	sql.Register(timedDriverName, timedDriver{&sqlite.Driver{}})
+}
+
+// Queries taking at least d are logged as slow. Zero or less turns the
+// logging off
+func SetSlowQueryThreshold(d time.Duration) {
+	slowQueryThreshold.Store(int64(d))
+}
+
+// Runs fn, counting it in Metrics and logging the query and the elapsed
+// time through slog when it takes longer than the slow query threshold
+func logQuery(query string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	logElapsed(query, time.Since(start), err)
+
+	return err
+}
+
+func logElapsed(query string, elapsed time.Duration, err error) {
+	queries.Add(1)
+
+	threshold := time.Duration(slowQueryThreshold.Load())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+
+	attrs := []any{"query", queryName(query), "duration", elapsed, "threshold", threshold}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	slog.Warn("slow query", attrs...)
+}
+
+// A query on one line, cut short so long statements stay readable in logs
+func queryName(query string) string {
+	name := strings.Join(strings.Fields(query), " ")
+	if len(name) > 120 {
+		name = name[:117] + "..."
+	}
+
+	return name
+}
+
+// Times statements at the driver, so the ones run on a transaction are
+// timed too, not only those going through a Store's queue
+type timedDriver struct {
+	driver.Driver
+}
+
+func (d timedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedConn{conn}, nil
+}
+
+type timedConn struct {
+	driver.Conn
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedStmt{stmt, query}, nil
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+
+	err := logQuery("BEGIN", func() error {
+		var err error
+		if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+			tx, err = beginner.BeginTx(ctx, opts)
+		} else {
+			tx, err = c.Conn.Begin()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return timedTx{tx}, nil
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+
+	err := logQuery(query, func() error {
+		var err error
+		result, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		logElapsed(query, time.Since(start), err)
+		return nil, err
+	}
+
+	return &timedRows{rows, query, start}, nil
+}
+
+type timedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+
+	err := logQuery(s.query, func() error {
+		var err error
+		result, err = execer.ExecContext(ctx, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		logElapsed(s.query, time.Since(start), err)
+		return nil, err
+	}
+
+	return &timedRows{rows, s.query, start}, nil
+}
+
+// A query's time runs until its rows are closed, so reading them counts
+type timedRows struct {
+	driver.Rows
+	query string
+	start time.Time
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	logElapsed(r.query, time.Since(r.start), err)
+	return err
+}
+
+func (r *timedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typed.ColumnTypeDatabaseTypeName(index)
+	}
+
+	return ""
+}
+
+func (r *timedRows) ColumnTypeScanType(index int) reflect.Type {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return typed.ColumnTypeScanType(index)
+	}
+
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+type timedTx struct {
+	driver.Tx
+}
+
+func (t timedTx) Commit() error {
+	return logQuery("COMMIT", t.Tx.Commit)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	var logged bytes.Buffer
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		SetSlowQueryThreshold(defaultSlowQueryThreshold)
+	})
+
+	s := newTestStore(t)
+
+	if err := s.InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	SetSlowQueryThreshold(50 * time.Millisecond)
+
+	// Reading the rows is part of the query's time
+	rows, err := s.queryContext(context.Background(), "SELECT term_crn FROM courses WHERE subject_code = 'COMP';")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+		time.Sleep(60 * time.Millisecond)
+	}
+
+	rows.Close()
+
+	if !strings.Contains(logged.String(), `msg="slow query" query="SELECT term_crn FROM courses WHERE subject_code = 'COMP';"`) {
+		t.Errorf("slow row iteration not logged:\n%s", logged.String())
+	}
+
+	logged.Reset()
+
+	if _, err = s.GetCourse("20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	if logged.Len() != 0 {
+		t.Errorf("fast queries logged as slow:\n%s", logged.String())
+	}
+
+	// Statements on a transaction don't go through the queue, but are
+	// timed all the same
+	SetSlowQueryThreshold(time.Nanosecond)
+
+	err = s.WithTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE courses SET title = 'Renamed' WHERE term_crn = '20241000001';")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"query=BEGIN", `query="UPDATE courses SET title = 'Renamed' WHERE term_crn = '20241000001';"`, "query=COMMIT"} {
+		if !strings.Contains(logged.String(), query) {
+			t.Errorf("%s not logged:\n%s", query, logged.String())
+		}
+	}
+
+	logged.Reset()
+	SetSlowQueryThreshold(0)
+
+	if _, err = s.GetCourse("20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	if logged.Len() != 0 {
+		t.Errorf("queries logged with the slow query log off:\n%s", logged.String())
+	}
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/util"
+)
+
+// A database along with the queue serializing access to it. The package
+// level functions all work on a default Store opened by Init, while
+// NewStore opens others, for example one per test
+type Store struct {
+	db    *sql.DB
+	queue *DBQueue
+
+	stmtsMu sync.Mutex
+	stmts   map[string]*sql.Stmt
+
+	cache *courseCache
+}
+
+var defaultStore = &Store{cache: newCourseCache(defaultCacheSize)}
+
+// Opens the database at path, which may be ":memory:", and brings its
+// schema up to date
+func NewStore(path string) (*Store, error) {
+	handle, err := OpenDatabaseAt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		db:    handle,
+		queue: newQueue(util.Config.Database.QueueSize),
+		cache: newCourseCache(defaultCacheSize),
+	}
+
+	if err = s.migrate(len(migrations)); err != nil {
+		handle.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// The default Store shares the package queue
+func (s *Store) getQueue() *DBQueue {
+	if s.queue == nil {
+		return GetQueue()
+	}
+
+	return s.queue
+}
+
+// Closes the database once every queued operation ahead of it has run.
+// Later calls return ErrDatabaseNotOpen, and closing an already closed
+// Store does nothing
+func (s *Store) Close() error {
+	return s.getQueue().EnqueueOperation(func() error {
+		if s.db == nil {
+			return nil
+		}
+
+		s.closeStatements()
+		s.cache.clear()
+		err := s.db.Close()
+		s.db = nil
+
+		return err
+	})
+}
+
+// Closes the default Store until the next Init
+func CloseDatabase() error {
+	return defaultStore.Close()
+}
+
+// Pings the default Store and runs a trivial query on it
+func HealthCheck(ctx context.Context) error {
+	return defaultStore.HealthCheck(ctx)
+}
+
+// Pings the database and runs a trivial query on it, returning an error
+// wrapping ErrDatabaseUnhealthy if either fails
+func (s *Store) HealthCheck(ctx context.Context) error {
+	return s.getQueue().EnqueueOperationContext(ctx, func() error {
+		if s.db == nil {
+			return fmt.Errorf("%w: %w", ErrDatabaseUnhealthy, ErrDatabaseNotOpen)
+		}
+
+		if err := s.db.PingContext(ctx); err != nil {
+			return fmt.Errorf("%w: ping: %w", ErrDatabaseUnhealthy, err)
+		}
+
+		var one int
+		if err := s.db.QueryRowContext(ctx, "SELECT 1;").Scan(&one); err != nil {
+			return fmt.Errorf("%w: select: %w", ErrDatabaseUnhealthy, err)
+		}
+
+		return nil
+	})
+}
+
+// Package level functions working on the default Store
+
+func InsertCourse(course courseload.Course) error {
+	return defaultStore.InsertCourse(course)
+}
+
+func InsertCourseContext(ctx context.Context, course courseload.Course) error {
+	return defaultStore.InsertCourseContext(ctx, course)
+}
+
+func BulkInsertCourses(courses []courseload.Course) error {
+	return defaultStore.BulkInsertCourses(courses)
+}
+
+func InsertCourses(courses []courseload.Course) ([]error, error) {
+	return defaultStore.InsertCourses(courses)
+}
+
+func UpdateCourse(course courseload.Course) error {
+	return defaultStore.UpdateCourse(course)
+}
+
+func UpdateCourseContext(ctx context.Context, course courseload.Course) error {
+	return defaultStore.UpdateCourseContext(ctx, course)
+}
+
+func InsertOrUpdateCourse(course courseload.Course) error {
+	return defaultStore.InsertOrUpdateCourse(course)
+}
+
+func DeleteCourse(term_crn string) error {
+	return defaultStore.DeleteCourse(term_crn)
+}
+
+func DeleteCourseContext(ctx context.Context, term_crn string) error {
+	return defaultStore.DeleteCourseContext(ctx, term_crn)
+}
+
+func SoftDeleteCourse(term_crn string) error {
+	return defaultStore.SoftDeleteCourse(term_crn)
+}
+
+func RestoreCourse(term_crn string) error {
+	return defaultStore.RestoreCourse(term_crn)
+}
+
+func PurgeArchived(before time.Time) error {
+	return defaultStore.PurgeArchived(before)
+}
+
+func GetCourse(term_crn string) (*courseload.Course, error) {
+	return defaultStore.GetCourse(term_crn)
+}
+
+func GetCourseContext(ctx context.Context, term_crn string) (*courseload.Course, error) {
+	return defaultStore.GetCourseContext(ctx, term_crn)
+}
+
+func GetCourseSummary(term_crn string) (*courseload.Course, error) {
+	return defaultStore.GetCourseSummary(term_crn)
+}
+
+func GetCourseCRNs() ([]string, error) {
+	return defaultStore.GetCourseCRNs()
+}
+
+func GetCourseCRNsContext(ctx context.Context) ([]string, error) {
+	return defaultStore.GetCourseCRNsContext(ctx)
+}
+
+func CountCourses() (int, error) {
+	return defaultStore.CountCourses()
+}
+
+func CountCoursesBySubject() (map[string]int, error) {
+	return defaultStore.CountCoursesBySubject()
+}
+
+func GetCourseCRNsPage(limit, offset int) ([]string, error) {
+	return defaultStore.GetCourseCRNsPage(limit, offset)
+}
+
+func GetCourseCRNsByPrefix(prefix string, limit int) ([]string, error) {
+	return defaultStore.GetCourseCRNsByPrefix(prefix, limit)
+}
+
+func GetRandomCourse() (*courseload.Course, error) {
+	return defaultStore.GetRandomCourse()
+}
+
+func GetCourses(crns []string) ([]courseload.Course, error) {
+	return defaultStore.GetCourses(crns)
+}
+
+func GetCoursesContext(ctx context.Context, crns []string) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesContext(ctx, crns)
+}
+
+func QueryCourse(key string, values ...string) ([]courseload.Course, error) {
+	return defaultStore.QueryCourse(key, values...)
+}
+
+func QueryCourseContext(ctx context.Context, key string, values ...string) ([]courseload.Course, error) {
+	return defaultStore.QueryCourseContext(ctx, key, values...)
+}
+
+func QueryCourseSubject(mode SubjectMatch, values ...string) ([]courseload.Course, error) {
+	return defaultStore.QueryCourseSubject(mode, values...)
+}
+
+func QueryCourseSubjectContext(ctx context.Context, mode SubjectMatch, values ...string) ([]courseload.Course, error) {
+	return defaultStore.QueryCourseSubjectContext(ctx, mode, values...)
+}
+
+func QueryCoursePage(key string, limit, offset int, values ...string) ([]courseload.Course, int, error) {
+	return defaultStore.QueryCoursePage(key, limit, offset, values...)
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCancelledContext(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241012345", "COMP", "405", "Software Engineering")
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetCourseContext", func() error {
+			s.ClearCache()
+			_, err := s.GetCourseContext(ctx, course.CRN)
+			return err
+		}},
+		{"QueryCourseContext", func() error {
+			_, err := s.QueryCourseContext(ctx, "title", "Software")
+			return err
+		}},
+		{"InsertCourseContext", func() error {
+			return s.InsertCourseContext(ctx, testCourse("20241054321", "COMP", "410", "Cancelled"))
+		}},
+		{"DeleteCourseContext", func() error {
+			return s.DeleteCourseContext(ctx, course.CRN)
+		}},
+	}
+
+	for _, test := range tests {
+		if err := test.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a cancelled context = %v, want context.Canceled", test.name, err)
+		}
+	}
+
+	if count, _ := s.CountCourses(); count != 1 {
+		t.Errorf("got %d courses after cancelled writes, want 1", count)
+	}
+}
+
+func TestStoresIsolated(t *testing.T) {
+	a, b := newTestStore(t), newTestStore(t)
+
+	course := testCourse("20241000001", "COMP", "400", "Data Structures", testMeeting("MWF", "9:10am-10:00am"))
+	course.Data.SeatsTotal, course.Data.SeatsAvailable = 30, 5
+
+	if err := a.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.InsertCourse(testCourse("20242000001", "MATH", "425", "Calculus")); err != nil {
+		t.Fatal(err)
+	}
+
+	if open, err := a.GetOpenCourses("COMP"); err != nil || len(open) != 1 {
+		t.Errorf("a.GetOpenCourses = %v, %v, want the COMP course", open, err)
+	}
+
+	if open, err := b.GetOpenCourses("COMP"); err != nil || len(open) != 0 {
+		t.Errorf("b.GetOpenCourses = %v, %v, want none", open, err)
+	}
+
+	if courses, err := b.GetCoursesByTerm("202410"); err != nil || len(courses) != 0 {
+		t.Errorf("b.GetCoursesByTerm = %v, %v, want none", courses, err)
+	}
+
+	if subjects, err := b.ListSubjects(); err != nil || len(subjects) != 1 || subjects[0] != "MATH" {
+		t.Errorf("b.ListSubjects = %v, %v, want [MATH]", subjects, err)
+	}
+
+	if instructors, err := a.GetCoursesByInstructor("Doe", "Jane"); err != nil || len(instructors) != 1 {
+		t.Errorf("a.GetCoursesByInstructor = %v, %v, want one course", instructors, err)
+	}
+
+	if conflicts, err := b.DetectConflicts([]string{course.CRN}); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("b.DetectConflicts = %v, %v, want ErrCourseNotFound", conflicts, err)
+	}
+
+	if changed, err := b.UpdateCourseField("title", "Data Structures", "Renamed"); err != nil || changed != 0 {
+		t.Errorf("b.UpdateCourseField changed %d rows, %v, want 0", changed, err)
+	}
+
+	if err := b.ArchiveTerm("202410"); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored, err := a.GetCourse(course.CRN); err != nil || stored.Data.Title != "Data Structures" {
+		t.Errorf("a.GetCourse after writes to b = %v, %v", stored, err)
+	}
+}
+
+func TestInitAtReplacesDatabase(t *testing.T) {
+	useTestDatabase(t)
+
+	if err := InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Caches the course and prepares statements on the first database
+	if _, err := GetCourse("20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	InitAt(t.TempDir() + "/other.sqlite")
+
+	if _, err := GetCourse("20241000001"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("GetCourse after InitAt = %v, want ErrCourseNotFound", err)
+	}
+
+	if err := InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Errorf("inserting into the new database: %v", err)
+	}
+}
+
+func TestQueryAfterClose(t *testing.T) {
+	s := newTestStore(t)
+
+	course := testCourse("20241000001", "COMP", "400", "Data Structures")
+	if err := s.InsertCourse(course); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetCourse(course.CRN); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("closing twice = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetCourse", func() error {
+			_, err := s.GetCourse(course.CRN)
+			return err
+		}},
+		{"QueryCourse", func() error {
+			_, err := s.QueryCourse("subject_code", "COMP")
+			return err
+		}},
+		{"CountCourses", func() error {
+			_, err := s.CountCourses()
+			return err
+		}},
+		{"InsertCourse", func() error {
+			return s.InsertCourse(testCourse("20241000002", "COMP", "401", "Closed"))
+		}},
+		{"UpdateCourse", func() error {
+			return s.UpdateCourse(course)
+		}},
+		{"DeleteCourse", func() error {
+			return s.DeleteCourse(course.CRN)
+		}},
+	}
+
+	for _, test := range tests {
+		if err := test.call(); !errors.Is(err, ErrDatabaseNotOpen) {
+			t.Errorf("%s after close = %v, want ErrDatabaseNotOpen", test.name, err)
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck on an open store = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.HealthCheck(ctx); err == nil {
+		t.Error("HealthCheck with a cancelled context succeeded")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.HealthCheck(context.Background())
+	if !errors.Is(err, ErrDatabaseUnhealthy) || !errors.Is(err, ErrDatabaseNotOpen) {
+		t.Errorf("HealthCheck after close = %v, want ErrDatabaseUnhealthy wrapping ErrDatabaseNotOpen", err)
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Common names students type in place of a subject code. To add an alias,
+// key it by its lowercase form with spaces and punctuation removed ("comp
+// sci" becomes "compsci") and map it to the subject code used in the
+// catalog. Callers may also add entries at startup
+var SubjectAliases = map[string]string{
+	"cs":               "COMP",
+	"compsci":          "COMP",
+	"computerscience":  "COMP",
+	"maths":            "MATH",
+	"mathematics":      "MATH",
+	"physics":          "PHYS",
+	"chemistry":        "CHEM",
+	"chem":             "CHEM",
+	"biology":          "BIOL",
+	"bio":              "BIOL",
+	"english":          "ENGL",
+	"psych":            "PSYC",
+	"psychology":       "PSYC",
+	"economics":        "ECON",
+	"econ":             "ECON",
+	"history":          "HIST",
+	"philosophy":       "PHIL",
+	"electricaleng":    "ECE",
+	"mechanicaleng":    "ME",
+	"civileng":         "CEE",
+	"informationtech":  "IT",
+	"businessadmin":    "ADMN",
+	"communication":    "CMN",
+	"communications":   "CMN",
+	"sociology":        "SOC",
+	"politicalscience": "POLT",
+	"polisci":          "POLT",
+}
+
+func aliasKey(input string) string {
+	var key strings.Builder
+
+	for _, r := range strings.ToLower(input) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+
+	return key.String()
+}
+
+// Resolves what a user typed to a subject code. The bool reports whether an
+// alias matched, otherwise the input is returned trimmed and uppercased
+func SuggestSubject(input string) (string, bool) {
+	if subject, ok := SubjectAliases[aliasKey(input)]; ok {
+		return subject, true
+	}
+
+	return strings.ToUpper(strings.TrimSpace(input)), false
+}
+
+var subjectNumberRegex = regexp.MustCompile(`^([A-Z]+)([0-9]+[A-Z]*)$`)
+
+// Splits inputs such as "cs401", "CS 401", "cs-401" or "CS 401H" into a
+// subject code and course number. The subject goes through SuggestSubject
+// so aliases like "compsci 401" work too
+func NormalizeSubjectNumber(input string) (subject, number string, err error) {
+	compact := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, input)
+
+	match := subjectNumberRegex.FindStringSubmatch(compact)
+
+	if match == nil {
+		return "", "", fmt.Errorf("%q is not a subject and course number", input)
+	}
+
+	subject, _ = SuggestSubject(match[1])
+
+	return subject, match[2], nil
+}
+
+// Every section of the course named by input, in any format accepted by
+// NormalizeSubjectNumber
+func GetCoursesByFuzzySubjectNumber(input string) ([]courseload.Course, error) {
+	return defaultStore.GetCoursesByFuzzySubjectNumber(input)
+}
+
+func (s *Store) GetCoursesByFuzzySubjectNumber(input string) ([]courseload.Course, error) {
+	subject, number, err := NormalizeSubjectNumber(input)
+	if err != nil {
+		return nil, err
+	}
+
+	crns, err := s.selectCRNs(context.Background(), "SELECT term_crn FROM courses WHERE subject_code = ? COLLATE NOCASE AND course_number = ? COLLATE NOCASE ORDER BY term_crn;", subject, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getCoursesByCRN(context.Background(), crns)
+}
+
+// Every section matching a course code typed by a user, such as "CS401",
+// with open sections first and by CRN otherwise. Returns ErrCourseNotFound
+// when no section matches
+func ResolveCourseCode(code string) ([]courseload.Course, error) {
+	return defaultStore.ResolveCourseCode(code)
+}
+
+func (s *Store) ResolveCourseCode(code string) ([]courseload.Course, error) {
+	courses, err := s.GetCoursesByFuzzySubjectNumber(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(courses) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, code)
+	}
+
+	sort.SliceStable(courses, func(i, j int) bool {
+		return courses[i].Data.SeatsAvailable > 0 && courses[j].Data.SeatsAvailable <= 0
+	})
+
+	return courses, nil
+}
+
+// Every subject code with at least one course, sorted
+func ListSubjects() ([]string, error) {
+	return defaultStore.ListSubjects()
+}
+
+func (s *Store) ListSubjects() ([]string, error) {
+	rows, err := s.queryContext(context.Background(), "SELECT DISTINCT subject_code FROM courses WHERE archived_at IS NULL ORDER BY subject_code;")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subjects := make([]string, 0)
+
+	for rows.Next() {
+		var subject_code string
+		if err := rows.Scan(&subject_code); err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, subject_code)
+	}
+
+	return subjects, rows.Err()
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListSubjects(t *testing.T) {
+	s := newTestStore(t)
+
+	if subjects, err := s.ListSubjects(); err != nil || len(subjects) != 0 {
+		t.Errorf("ListSubjects on an empty database = %v, %v, want none", subjects, err)
+	}
+
+	for i, subject := range []string{"MATH", "COMP", "ENGL", "COMP", "MATH", "ARTS"} {
+		if err := s.InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), subject, "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.SoftDeleteCourse("20241000005"); err != nil {
+		t.Fatal(err)
+	}
+
+	subjects, err := s.ListSubjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"COMP", "ENGL", "MATH"}; !slices.Equal(subjects, want) {
+		t.Errorf("ListSubjects = %v, want %v", subjects, want)
+	}
+}
+
+func TestNormalizeSubjectNumber(t *testing.T) {
+	for _, test := range []struct {
+		input, subject, number string
+	}{
+		{"cs401", "COMP", "401"},
+		{"CS 401", "COMP", "401"},
+		{"cs-401", "COMP", "401"},
+		{"CS 401H", "COMP", "401H"},
+		{" compsci 401 ", "COMP", "401"},
+		{"math425", "MATH", "425"},
+		{"ENGL.401", "ENGL", "401"},
+	} {
+		subject, number, err := NormalizeSubjectNumber(test.input)
+		if err != nil || subject != test.subject || number != test.number {
+			t.Errorf("NormalizeSubjectNumber(%q) = %q, %q, %v, want %q, %q", test.input, subject, number, err, test.subject, test.number)
+		}
+	}
+
+	for _, input := range []string{"401H", "CS", "", "401 CS", "CS 4O1"} {
+		if subject, number, err := NormalizeSubjectNumber(input); err == nil {
+			t.Errorf("NormalizeSubjectNumber(%q) = %q, %q, want an error", input, subject, number)
+		}
+	}
+}
+
+func TestGetCoursesByFuzzySubjectNumber(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, course := range []struct {
+		crn, subject, number string
+	}{
+		{"20241000002", "COMP", "401"},
+		{"20241000001", "COMP", "401"},
+		{"20241000003", "COMP", "401H"},
+		{"20241000004", "MATH", "401"},
+		{"20241000005", "COMP", "401"},
+	} {
+		if err := s.InsertCourse(testCourse(course.crn, course.subject, course.number, "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.SoftDeleteCourse("20241000005"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		input string
+		want  []string
+	}{
+		{"cs-401", []string{"20241000001", "20241000002"}},
+		{"COMP 401", []string{"20241000001", "20241000002"}},
+		{"cs 401h", []string{"20241000003"}},
+		{"math401", []string{"20241000004"}},
+		{"COMP 999", nil},
+	} {
+		courses, err := s.GetCoursesByFuzzySubjectNumber(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var crns []string
+		for _, course := range courses {
+			crns = append(crns, course.CRN)
+		}
+
+		if !slices.Equal(crns, test.want) {
+			t.Errorf("GetCoursesByFuzzySubjectNumber(%q) = %v, want %v", test.input, crns, test.want)
+		}
+	}
+
+	if _, err := s.GetCoursesByFuzzySubjectNumber("401H"); err == nil {
+		t.Error("GetCoursesByFuzzySubjectNumber without a subject succeeded")
+	}
+}
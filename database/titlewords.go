@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// Words left out of title word counts. Entries are lowercase and callers may
+// add or remove words at startup
+var TitleStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "by": true,
+	"for": true, "from": true, "in": true, "into": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "with": true, "i": true, "ii": true,
+	"iii": true, "iv": true, "intro": true, "introduction": true,
+	"topics": true, "special": true,
+}
+
+// The topN most common non-stopwords across a subject's course titles,
+// most frequent first with ties in alphabetical order
+func TitleWordFrequency(subject string, topN int) ([]WordCount, error) {
+	if topN <= 0 {
+		return nil, fmt.Errorf("topN must be positive")
+	}
+
+	subject, _ = SuggestSubject(subject)
+
+	rows, err := QueuedQuery("SELECT title FROM courses WHERE subject_code = ? AND archived_at IS NULL;", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var title string
+		if err = rows.Scan(&title); err != nil {
+			return nil, err
+		}
+
+		words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		for _, word := range words {
+			if len(word) > 1 && !TitleStopwords[word] && strings.IndexFunc(word, unicode.IsLetter) != -1 {
+				counts[word]++
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	frequencies := make([]WordCount, 0, len(counts))
+
+	for word, count := range counts {
+		frequencies = append(frequencies, WordCount{Word: word, Count: count})
+	}
+
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].Count != frequencies[j].Count {
+			return frequencies[i].Count > frequencies[j].Count
+		}
+
+		return frequencies[i].Word < frequencies[j].Word
+	})
+
+	if len(frequencies) > topN {
+		frequencies = frequencies[:topN]
+	}
+
+	return frequencies, nil
+}
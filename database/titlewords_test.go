@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestTitleWordFrequency(t *testing.T) {
+	useTestDatabase(t)
+
+	for i, course := range []struct {
+		subject, title string
+	}{
+		{"COMP", "Introduction to Data Structures"},
+		{"COMP", "Data Science and Machine Learning"},
+		{"COMP", "Machine Learning II"},
+		{"COMP", "Special Topics in Security"},
+		{"COMP", "Software Engineering"},
+		{"COMP", "Programming 101"},
+		{"MATH", "Data Analysis"},
+		{"COMP", "Data Mining"},
+	} {
+		if err := InsertCourse(testCourse(fmt.Sprintf("202410%05d", i), course.subject, "400", course.title)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SoftDeleteCourse("20241000007"); err != nil {
+		t.Fatal(err)
+	}
+
+	frequencies := func(subject string, topN int) []WordCount {
+		t.Helper()
+
+		words, err := TitleWordFrequency(subject, topN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return words
+	}
+
+	want := []WordCount{{"data", 2}, {"learning", 2}, {"machine", 2}, {"engineering", 1}}
+	if got := frequencies("cs", 4); !slices.Equal(got, want) {
+		t.Errorf("TitleWordFrequency(cs, 4) = %v, want %v", got, want)
+	}
+
+	all := frequencies("COMP", 100)
+	if len(all) != 9 {
+		t.Errorf("got %d words, want 9 with stopwords and numbers left out: %v", len(all), all)
+	}
+
+	for _, word := range all {
+		if TitleStopwords[word.Word] || word.Word == "101" || word.Word == "analysis" || word.Word == "mining" {
+			t.Errorf("counted %q", word.Word)
+		}
+	}
+
+	TitleStopwords["data"] = true
+	t.Cleanup(func() { delete(TitleStopwords, "data") })
+
+	if got := frequencies("COMP", 1); !slices.Equal(got, []WordCount{{"learning", 2}}) {
+		t.Errorf("with data as a stopword got %v, want learning first", got)
+	}
+
+	if _, err := TitleWordFrequency("COMP", 0); err == nil {
+		t.Error("TitleWordFrequency with topN 0 succeeded")
+	}
+}
@@ -1,23 +1,41 @@
 package database
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func CreateUser(email, first, last, password string) (*User, int) {
+	return CreateUserContext(context.Background(), email, first, last, password)
+}
+
+func CreateUserContext(ctx context.Context, email, first, last, password string) (*User, int) {
 	// Check if user already exists
-	_, err := GetUser(email)
+	_, err := GetUserContext(ctx, email)
 
 	if err == nil {
 		return nil, CREATE_USER_ERROR_IMUsed
 	}
 
-	err = QueuedExec(INSERT_USER_STATEMENT, email, first, last, HashPassword(password), "")
+	hash, err := HashPassword(password)
+	if err != nil {
+		// bcrypt rejects passwords longer than 72 bytes
+		return nil, CREATE_USER_ERROR_BadRequest
+	}
+
+	err = QueuedExecContext(ctx, INSERT_USER_STATEMENT, email, first, last, hash, encodeClasses(nil))
 	if err != nil {
 		return nil, CREATE_USER_ERROR_InternalServerError
 	}
 
-	if user, err := GetUser(email); err == nil {
+	if user, err := GetUserContext(ctx, email); err == nil {
 		return user, 0
 	} else {
 		return nil, CREATE_USER_ERROR_InternalServerError
@@ -25,89 +43,427 @@ func CreateUser(email, first, last, password string) (*User, int) {
 }
 
 func GetUser(email string) (*User, error) {
-	row := QueuedQueryRow(SELECT_USER_STATEMENT, email)
+	return GetUserContext(context.Background(), email)
+}
+
+func GetUserContext(ctx context.Context, email string) (*User, error) {
+	row := QueuedQueryRowContext(ctx, SELECT_USER_STATEMENT, email)
 
-	var intPtr int
 	var user User
 	var courses string
-	err := row.Scan(&intPtr, &user.Email, &user.FirstName, &user.LastName, &user.PasswordHash, &courses, &user.Privilege)
-	if err != nil {
+	err := row.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &courses, &user.Privilege)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	} else if err != nil {
 		return nil, err
 	}
 
-	for _, class := range strings.Split(courses, ",") {
-		if class != "" {
-			user.Courses = append(user.Courses, class)
+	user.Courses = parseClasses(courses)
+
+	return &user, nil
+}
+
+// Checks a password against the stored hash. Hashes from before bcrypt are
+// upgraded in place the first time they verify
+func VerifyUser(email, password string) (bool, error) {
+	return VerifyUserContext(context.Background(), email, password)
+}
+
+func VerifyUserContext(ctx context.Context, email, password string) (bool, error) {
+	var hash string
+
+	err := QueuedQueryRowContext(ctx, "SELECT password FROM users WHERE email = ?;", email).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	} else if err != nil {
+		return false, err
+	}
+
+	if ok, err := checkPassword(hash, password); !ok || err != nil {
+		return ok, err
+	}
+
+	if !strings.HasPrefix(hash, "$2") {
+		if upgraded, err := HashPassword(password); err == nil {
+			QueuedExecContext(ctx, "UPDATE users SET password = ? WHERE email = ?;", upgraded, email)
 		}
 	}
 
-	return &user, nil
+	return true, nil
+}
+
+// Whether password matches a stored bcrypt or legacy hash
+func checkPassword(hash, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$2") {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(legacyHashPassword(password))) == 1, nil
+}
+
+// Replaces the user's password, provided oldPassword is the current one.
+// Returns ErrWrongPassword otherwise. Like modifyUserClasses, the current
+// hash is read by a no-op UPDATE so the write lock is taken first
+func ChangePassword(email, oldPassword, newPassword string) error {
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return withRetry(func() error {
+		transaction, err := QueuedBegin()
+		if err != nil {
+			return err
+		}
+
+		var current string
+
+		err = transaction.QueryRow("UPDATE users SET password = password WHERE email = ? RETURNING password;", email).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			transaction.Rollback()
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		} else if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		if ok, err := checkPassword(current, oldPassword); err != nil {
+			transaction.Rollback()
+			return err
+		} else if !ok {
+			transaction.Rollback()
+			return ErrWrongPassword
+		}
+
+		if _, err = transaction.Exec("UPDATE users SET password = ? WHERE email = ?;", hash, email); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		return transaction.Commit()
+	})
+}
+
+// Changes the email a user signs in with, which is what identifies them,
+// carrying their sessions and watches over. Returns ErrEmailTaken if
+// another user already has newEmail. The user's row is locked by a no-op
+// UPDATE before anything is read, as in modifyUserClasses
+func RenameUser(oldEmail, newEmail string) error {
+	return withRetry(func() error {
+		transaction, err := QueuedBegin()
+		if err != nil {
+			return err
+		}
+
+		var exists int
+
+		err = transaction.QueryRow("UPDATE users SET email = email WHERE email = ? RETURNING 1;", oldEmail).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			transaction.Rollback()
+			return fmt.Errorf("%w: %s", ErrUserNotFound, oldEmail)
+		} else if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		err = transaction.QueryRow("SELECT 1 FROM users WHERE email = ?;", newEmail).Scan(&exists)
+		if err == nil {
+			transaction.Rollback()
+			return fmt.Errorf("%w: %s", ErrEmailTaken, newEmail)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			transaction.Rollback()
+			return err
+		}
+
+		if _, err = transaction.Exec("UPDATE users SET email = ? WHERE email = ?;", newEmail, oldEmail); err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		for _, table := range []string{"sessions", "watches"} {
+			if _, err = transaction.Exec("UPDATE "+table+" SET email = ? WHERE email = ?;", newEmail, oldEmail); err != nil {
+				transaction.Rollback()
+				return err
+			}
+		}
+
+		return transaction.Commit()
+	})
 }
 
-func DeleteUser(username string) error {
-	return QueuedExec("DELETE FROM users WHERE username = ?;", username)
+func DeleteUser(email string) error {
+	return DeleteUserContext(context.Background(), email)
+}
+
+func DeleteUserContext(ctx context.Context, email string) error {
+	return QueuedExecContext(ctx, "DELETE FROM users WHERE email = ?;", email)
 }
 
 func AllUsers() ([]User, error) {
-	rows, err := QueuedQuery("SELECT id, email, first_name, last_name, password, classes, privilege FROM users;")
+	return AllUsersContext(context.Background())
+}
+
+func AllUsersContext(ctx context.Context) ([]User, error) {
+	rows, err := QueuedQueryContext(ctx, SELECT_USERS_STATEMENT)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	users := make([]User, 0)
 
 	for rows.Next() {
-		var idPtr int
 		var user User
 		var courses string
-		err := rows.Scan(&idPtr, &user.Email, &user.FirstName, &user.LastName, &user.PasswordHash, &courses, &user.Privilege)
+		err := rows.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &courses, &user.Privilege)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, class := range strings.Split(courses, ",") {
-			if class != "" {
-				user.Courses = append(user.Courses, class)
-			}
-		}
+		user.Courses = parseClasses(courses)
 
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
-// Every user with 1+ courses in common with the given user
+// Every user with the given CRN among their classes, matched without
+// regard to case
 func UsersInCourse(crn string) ([]User, error) {
-	rows, err := QueuedQuery("SELECT id, email, first_name, last_name, password, classes, privilege FROM users;")
+	return UsersInCourseContext(context.Background(), crn)
+}
+
+func UsersInCourseContext(ctx context.Context, crn string) ([]User, error) {
+	rows, err := QueuedQueryContext(ctx, SELECT_USERS_STATEMENT)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	users := make([]User, 0)
 
 	for rows.Next() {
-		var idPtr int
 		var u User
 		var courses string
-		err := rows.Scan(&idPtr, &u.Email, &u.FirstName, &u.LastName, &u.PasswordHash, &courses, &u.Privilege)
+		err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &courses, &u.Privilege)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, class := range strings.Split(courses, ",") {
-			if class != "" {
-				u.Courses = append(u.Courses, class)
-			}
-		}
+		u.Courses = parseClasses(courses)
 
 		for _, class := range u.Courses {
-			if class == crn {
+			if strings.EqualFold(class, crn) {
 				users = append(users, u)
 				break
 			}
 		}
 	}
 
-	return users, nil
+	return users, rows.Err()
+}
+
+// Reads the classes column, a JSON array of CRNs. Rows written before the
+// column moved to JSON hold a comma separated list and are read as such
+func parseClasses(raw string) []string {
+	var classes []string
+
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &classes); err == nil {
+			return classes
+		}
+	}
+
+	for _, class := range strings.Split(raw, ",") {
+		if class != "" {
+			classes = append(classes, class)
+		}
+	}
+
+	return classes
+}
+
+func encodeClasses(classes []string) string {
+	if classes == nil {
+		classes = []string{}
+	}
+
+	bytes, _ := json.Marshal(classes)
+	return string(bytes)
+}
+
+func GetUserClasses(email string) ([]string, error) {
+	var classes string
+
+	err := QueuedQueryRow("SELECT classes FROM users WHERE email = ?;", email).Scan(&classes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parseClasses(classes), nil
+}
+
+// Replaces a user's classes after checking every CRN exists, storing each
+// CRN as it's cased in the courses table
+func SetUserClasses(email string, crns []string) error {
+	classes := make([]string, 0, len(crns))
+
+	for _, crn := range crns {
+		var term_crn string
+
+		err := QueuedQueryRow("SELECT term_crn FROM courses WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;", crn).Scan(&term_crn)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown CRN %s: %w", crn, ErrCourseNotFound)
+		} else if err != nil {
+			return err
+		}
+
+		classes = append(classes, term_crn)
+	}
+
+	result, err := QueuedExecResult("UPDATE users SET classes = ? WHERE email = ?;", encodeClasses(classes), email)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+
+	return nil
+}
+
+// Adds a CRN to a user's classes. Adding a CRN the user already has is a
+// no-op, and unknown CRNs return ErrCourseNotFound
+func AddUserClass(email, crn string) error {
+	return modifyUserClasses(email, func(transaction *sql.Tx, classes []string) ([]string, error) {
+		var term_crn string
+
+		err := transaction.QueryRow("SELECT term_crn FROM courses WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;", crn).Scan(&term_crn)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, crn)
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, class := range classes {
+			if class == term_crn {
+				return classes, nil
+			}
+		}
+
+		return append(classes, term_crn), nil
+	})
+}
+
+// Adds a CRN to a user's classes and takes one of its available seats in
+// the same transaction, so concurrent enrollments can't overfill it. The
+// seats are checked again inside the transaction, and ErrCourseFull is
+// returned with nothing changed when none are left. Enrolling in a course
+// the user already has is a no-op, and a course whose seats aren't known
+// is enrolled in without counting
+func EnrollTransactional(email, crn string) error {
+	var term_crn string
+
+	err := modifyUserClasses(email, func(transaction *sql.Tx, classes []string) ([]string, error) {
+		var seats_total int
+
+		err := transaction.QueryRow("SELECT term_crn, seats_total FROM courses WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;", crn).Scan(&term_crn, &seats_total)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrCourseNotFound, crn)
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, class := range classes {
+			if class == term_crn {
+				return classes, nil
+			}
+		}
+
+		if seats_total > 0 {
+			result, err := transaction.Exec("UPDATE courses SET seats_available = seats_available - 1 WHERE term_crn = ? AND seats_available > 0;", term_crn)
+			if err != nil {
+				return nil, err
+			}
+
+			if affected, err := result.RowsAffected(); err != nil {
+				return nil, err
+			} else if affected == 0 {
+				return nil, fmt.Errorf("%w: %s", ErrCourseFull, term_crn)
+			}
+		}
+
+		return append(classes, term_crn), nil
+	})
+
+	if err == nil {
+		defaultStore.cache.remove(term_crn)
+	}
+
+	return err
+}
+
+// Removes a CRN from a user's classes, returning ErrNotEnrolled when the
+// user doesn't have it
+func RemoveUserClass(email, crn string) error {
+	return modifyUserClasses(email, func(transaction *sql.Tx, classes []string) ([]string, error) {
+		for i, class := range classes {
+			if strings.EqualFold(class, crn) {
+				return append(classes[:i], classes[i+1:]...), nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrNotEnrolled, crn)
+	})
+}
+
+// Read-modify-write of a user's classes inside one transaction so that
+// concurrent edits can't overwrite each other. The classes are read by a
+// no-op UPDATE so the transaction holds the write lock from its first
+// statement. Reading first would let another edit commit in between, which
+// SQLite reports as SQLITE_BUSY_SNAPSHOT instead of waiting
+func modifyUserClasses(email string, modify func(transaction *sql.Tx, classes []string) ([]string, error)) error {
+	return withRetry(func() error {
+		transaction, err := QueuedBegin()
+		if err != nil {
+			return err
+		}
+
+		var raw string
+
+		err = transaction.QueryRow("UPDATE users SET classes = classes WHERE email = ? RETURNING classes;", email).Scan(&raw)
+		if errors.Is(err, sql.ErrNoRows) {
+			transaction.Rollback()
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		} else if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		classes, err := modify(transaction, parseClasses(raw))
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		_, err = transaction.Exec("UPDATE users SET classes = ? WHERE email = ?;", encodeClasses(classes), email)
+		if err != nil {
+			transaction.Rollback()
+			return err
+		}
+
+		return transaction.Commit()
+	})
 }
@@ -0,0 +1,460 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestConcurrentAddUserClass(t *testing.T) {
+	useTestDatabase(t)
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	crns := make([]string, 20)
+	for i := range crns {
+		crns[i] = fmt.Sprintf("202410%05d", i)
+
+		if err := InsertCourse(testCourse(crns[i], "COMP", "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(crns))
+
+	for i, crn := range crns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = AddUserClass(email, crn)
+		}()
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("AddUserClass(%s): %v", crns[i], err)
+		}
+	}
+
+	classes, err := GetUserClasses(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(classes)
+	if !slices.Equal(classes, crns) {
+		t.Errorf("classes = %v, want all %d CRNs", classes, len(crns))
+	}
+
+	if err = AddUserClass("nobody@unh.edu", crns[0]); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("AddUserClass for a missing user = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	useTestDatabase(t)
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	if err := ChangePassword(email, "wrong", "new password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong old password error = %v, want ErrWrongPassword", err)
+	}
+
+	if ok, err := VerifyUser(email, "password"); err != nil || !ok {
+		t.Errorf("password changed by a failed ChangePassword: %v, %v", ok, err)
+	}
+
+	if err := ChangePassword(email, "password", "new password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := VerifyUser(email, "new password"); err != nil || !ok {
+		t.Errorf("new password rejected: %v, %v", ok, err)
+	}
+
+	if ok, err := VerifyUser(email, "password"); err != nil || ok {
+		t.Errorf("old password still accepted: %v, %v", ok, err)
+	}
+
+	if err := ChangePassword("nobody@unh.edu", "password", "new password"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("missing user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestRenameUser(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, email := range []string{"a@unh.edu", "b@unh.edu"} {
+		if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", email, status)
+		}
+	}
+
+	if err := InsertCourse(testCourse("20241000001", "COMP", "400", "Data Structures")); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := CreateSession("a@unh.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = AddWatch("a@unh.edu", "20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RenameUser("a@unh.edu", "b@unh.edu"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("collision error = %v, want ErrEmailTaken", err)
+	}
+
+	if err = RenameUser("nobody@unh.edu", "c@unh.edu"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("missing user error = %v, want ErrUserNotFound", err)
+	}
+
+	if err = RenameUser("a@unh.edu", "c@unh.edu"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = GetUser("a@unh.edu"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("old email still found: %v", err)
+	}
+
+	if ok, err := VerifyUser("c@unh.edu", "password"); err != nil || !ok {
+		t.Errorf("renamed user can't sign in: %v, %v", ok, err)
+	}
+
+	if email, err := GetSession(token); err != nil || email != "c@unh.edu" {
+		t.Errorf("session email = %q, %v, want c@unh.edu", email, err)
+	}
+
+	var watches int
+	if err = defaultStore.db.QueryRow("SELECT COUNT(*) FROM watches WHERE email = 'c@unh.edu';").Scan(&watches); err != nil || watches != 1 {
+		t.Errorf("watches carried over = %d, %v, want 1", watches, err)
+	}
+}
+
+func TestVerifyUserPasswords(t *testing.T) {
+	useTestDatabase(t)
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "correct horse"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	var stored string
+	if err := defaultStore.db.QueryRow("SELECT password FROM users WHERE email = ?;", email).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(stored, "$2") || bcrypt.CompareHashAndPassword([]byte(stored), []byte("correct horse")) != nil {
+		t.Errorf("stored password %q is not a bcrypt hash of the password", stored)
+	}
+
+	if ok, err := VerifyUser(email, "correct horse"); err != nil || !ok {
+		t.Errorf("right password = %v, %v, want true", ok, err)
+	}
+
+	if ok, err := VerifyUser(email, "wrong horse"); err != nil || ok {
+		t.Errorf("wrong password = %v, %v, want false", ok, err)
+	}
+
+	if _, err := VerifyUser("nobody@unh.edu", "correct horse"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("missing user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestVerifyUserUpgradesLegacyHash(t *testing.T) {
+	useTestDatabase(t)
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "placeholder"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	if _, err := defaultStore.db.Exec("UPDATE users SET password = ? WHERE email = ?;", legacyHashPassword("correct horse"), email); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := VerifyUser(email, "wrong horse"); err != nil || ok {
+		t.Errorf("wrong password against a legacy hash = %v, %v, want false", ok, err)
+	}
+
+	if ok, err := VerifyUser(email, "correct horse"); err != nil || !ok {
+		t.Fatalf("right password against a legacy hash = %v, %v, want true", ok, err)
+	}
+
+	var stored string
+	if err := defaultStore.db.QueryRow("SELECT password FROM users WHERE email = ?;", email).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(stored, "$2") {
+		t.Errorf("legacy hash not upgraded to bcrypt after login")
+	}
+
+	if ok, err := VerifyUser(email, "correct horse"); err != nil || !ok {
+		t.Errorf("right password against the upgraded hash = %v, %v, want true", ok, err)
+	}
+}
+
+func TestCreateUserLongPassword(t *testing.T) {
+	useTestDatabase(t)
+
+	if _, status := CreateUser("a@unh.edu", "A", "B", strings.Repeat("x", 73)); status != CREATE_USER_ERROR_BadRequest {
+		t.Errorf("73 byte password status = %d, want CREATE_USER_ERROR_BadRequest", status)
+	}
+
+	if _, err := GetUser("a@unh.edu"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("user created despite the rejected password: %v", err)
+	}
+
+	if _, status := CreateUser("b@unh.edu", "A", "B", strings.Repeat("x", 72)); status != CREATE_USER_SUCCESS {
+		t.Errorf("72 byte password status = %d, want CREATE_USER_SUCCESS", status)
+	}
+}
+
+func TestEnrollTransactional(t *testing.T) {
+	useTestDatabase(t)
+
+	course := testCourse("20241000001", "COMP", "400", "Data Structures")
+	course.Data.SeatsTotal, course.Data.SeatsAvailable = 30, 3
+
+	for _, course := range []courseload.Course{course, testCourse("20241000002", "COMP", "405", "Software Engineering")} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emails := make([]string, 10)
+	for i := range emails {
+		emails[i] = fmt.Sprintf("user%d@unh.edu", i)
+
+		if _, status := CreateUser(emails[i], "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", emails[i], status)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(emails))
+
+	for i, email := range emails {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = EnrollTransactional(email, "20241000001")
+		}()
+	}
+
+	wg.Wait()
+
+	enrolled := 0
+	for i, err := range errs {
+		if err == nil {
+			enrolled++
+		} else if !errors.Is(err, ErrCourseFull) {
+			t.Errorf("EnrollTransactional(%s): %v", emails[i], err)
+		}
+	}
+
+	users, err := UsersInCourse("20241000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetCourse("20241000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if enrolled != 3 || len(users) != 3 || stored.Data.SeatsAvailable != 0 {
+		t.Errorf("%d enrolled, %d users with the course and %d seats left, want 3, 3 and 0", enrolled, len(users), stored.Data.SeatsAvailable)
+	}
+
+	// Enrolling again takes no seat, whether or not any are left
+	if err = EnrollTransactional(users[0].Email, "20241000001"); err != nil {
+		t.Errorf("enrolling twice: %v", err)
+	}
+
+	if err = EnrollTransactional(emails[0], "20241000002"); err != nil {
+		t.Errorf("enrolling in a course with unknown seats: %v", err)
+	}
+
+	if err = EnrollTransactional(emails[0], "20241099999"); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("missing course error = %v, want ErrCourseNotFound", err)
+	}
+
+	if err = EnrollTransactional("nobody@unh.edu", "20241000002"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("missing user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUsersInCourse(t *testing.T) {
+	useTestDatabase(t)
+
+	for _, crn := range []string{"2024100000A", "2024100000B"} {
+		if err := InsertCourse(testCourse(crn, "COMP", "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for email, classes := range map[string][]string{
+		"a@unh.edu": {"2024100000A"},
+		"b@unh.edu": {"2024100000B", "2024100000A"},
+		"c@unh.edu": {"2024100000B"},
+		"d@unh.edu": nil,
+	} {
+		if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", email, status)
+		}
+
+		if err := SetUserClasses(email, classes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, crn := range []string{"2024100000A", "2024100000a"} {
+		users, err := UsersInCourse(crn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		emails := make([]string, 0, len(users))
+		for _, user := range users {
+			emails = append(emails, user.Email)
+		}
+
+		slices.Sort(emails)
+		if !slices.Equal(emails, []string{"a@unh.edu", "b@unh.edu"}) {
+			t.Errorf("UsersInCourse(%q) = %v, want a@unh.edu and b@unh.edu", crn, emails)
+		}
+	}
+
+	if users, err := UsersInCourse("20241099999"); err != nil || len(users) != 0 {
+		t.Errorf("UsersInCourse for an unknown CRN = %v, %v, want none", users, err)
+	}
+}
+
+func TestSetUserClasses(t *testing.T) {
+	useTestDatabase(t)
+
+	const email = "a@unh.edu"
+
+	if _, status := CreateUser(email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	crns := []string{"2024100000C", "2024100000A", "2024100000B"}
+	for _, crn := range crns {
+		if err := InsertCourse(testCourse(crn, "COMP", "400", "Course")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw := func() string {
+		var classes string
+		if err := QueuedQueryRow("SELECT classes FROM users WHERE email = ?;", email).Scan(&classes); err != nil {
+			t.Fatal(err)
+		}
+		return classes
+	}
+
+	if err := SetUserClasses(email, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := raw(); got != "[]" {
+		t.Errorf("empty classes stored as %q, want []", got)
+	}
+
+	if classes, err := GetUserClasses(email); err != nil || len(classes) != 0 {
+		t.Errorf("GetUserClasses = %v, %v, want none", classes, err)
+	}
+
+	if err := SetUserClasses(email, []string{"2024100000c", "2024100000A", "2024100000B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := raw(); got != `["2024100000C","2024100000A","2024100000B"]` {
+		t.Errorf("classes stored as %q, want a JSON array", got)
+	}
+
+	if classes, err := GetUserClasses(email); err != nil || !slices.Equal(classes, crns) {
+		t.Errorf("GetUserClasses = %v, %v, want %v", classes, err, crns)
+	}
+
+	if err := SetUserClasses(email, []string{"2024100000A", "bogus"}); !errors.Is(err, ErrCourseNotFound) {
+		t.Errorf("SetUserClasses with a bogus CRN = %v, want ErrCourseNotFound", err)
+	}
+
+	if classes, _ := GetUserClasses(email); !slices.Equal(classes, crns) {
+		t.Errorf("classes after a rejected update = %v, want %v", classes, crns)
+	}
+
+	if err := SetUserClasses("nobody@unh.edu", nil); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetUserClasses for a missing user = %v, want ErrUserNotFound", err)
+	}
+
+	if err := QueuedExec("UPDATE users SET classes = ? WHERE email = ?;", "2024100000A,2024100000B", email); err != nil {
+		t.Fatal(err)
+	}
+
+	if classes, err := GetUserClasses(email); err != nil || !slices.Equal(classes, crns[1:]) {
+		t.Errorf("legacy classes read as %v, %v, want %v", classes, err, crns[1:])
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	useTestDatabase(t)
+
+	created, status := CreateUser("a@unh.edu", "Jane", "Doe", "password")
+	if status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	other, status := CreateUser("b@unh.edu", "Sam", "Roe", "password")
+	if status != CREATE_USER_SUCCESS {
+		t.Fatalf("creating user: %d", status)
+	}
+
+	if err := InsertCourse(testCourse("20241000001", "COMP", "400", "Course")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AddUserClass("a@unh.edu", "20241000001"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := GetUser("a@unh.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := User{ID: created.ID, Email: "a@unh.edu", FirstName: "Jane", LastName: "Doe", Courses: []string{"20241000001"}}
+	if user.ID == 0 || user.ID == other.ID || !reflect.DeepEqual(*user, want) {
+		t.Errorf("GetUser = %+v, want %+v with an ID of its own", *user, want)
+	}
+
+	if user, err = GetUser("nobody@unh.edu"); !errors.Is(err, ErrUserNotFound) || user != nil {
+		t.Errorf("GetUser for a missing user = %v, %v, want ErrUserNotFound", user, err)
+	}
+}
@@ -2,50 +2,64 @@ package database
 
 import (
 	"crypto/sha256"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/mail"
 	"strings"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
+	"hacknhbackend.eparker.dev/courseload"
 	"hacknhbackend.eparker.dev/util"
 )
 
-func HashPassword(password string) string {
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// Salted sha256 used before passwords moved to bcrypt, only kept to verify
+// and upgrade existing hashes
+func legacyHashPassword(password string) string {
 	hash := sha256.New()
 	hash.Write([]byte(util.Config.Database.PasswordSalt + password))
 	return string(hash.Sum(nil))
 }
 
+// A user as the rest of the package sees one. The password hash is left
+// out on purpose, VerifyUser being the only thing that reads it
 type User struct {
-	Email, FirstName, LastName, PasswordHash string
-	Courses                                  []string
-	Privilege                                int
+	ID                         int
+	Email, FirstName, LastName string
+	Courses                    []string
+	Privilege                  int
 }
 
 func (u *User) AddClass(crn string) error {
-	if _, err := GetCourse(crn); err != nil {
-		return nil
-	}
+	err := AddUserClass(u.Email, crn)
 
-	for _, class := range u.Courses {
-		if class == crn {
-			return nil
-		}
+	// Unknown courses are ignored rather than reported
+	if errors.Is(err, ErrCourseNotFound) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	u.Courses = append(u.Courses, crn)
-
-	return QueuedExec("UPDATE users SET classes = ? WHERE email = ?;", strings.Join(u.Courses, ","), u.Email)
+	u.Courses, err = GetUserClasses(u.Email)
+	return err
 }
 
 func (u *User) RemoveClass(crn string) error {
-	for i, class := range u.Courses {
-		if class == crn {
-			u.Courses = append(u.Courses[:i], u.Courses[i+1:]...)
-			break
-		}
+	err := RemoveUserClass(u.Email, crn)
+	if err != nil {
+		return err
 	}
 
-	return QueuedExec("UPDATE users SET classes = ? WHERE email = ?;", strings.Join(u.Courses, ","), u.Email)
+	u.Courses, err = GetUserClasses(u.Email)
+	return err
 }
 
 func (u *User) ChangeName(first, last string) error {
@@ -97,3 +111,58 @@ const (
 )
 
 var ErrorQueueTimeout error = fmt.Errorf("queue timeout")
+var ErrDatabaseNotOpen error = fmt.Errorf("database not open")
+var ErrDatabaseUnhealthy error = fmt.Errorf("database unhealthy")
+var ErrCourseNotFound error = fmt.Errorf("course not found")
+var ErrUserNotFound error = fmt.Errorf("user not found")
+var ErrNotEnrolled error = fmt.Errorf("not enrolled in course")
+var ErrSessionNotFound error = fmt.Errorf("session not found")
+var ErrSessionExpired error = fmt.Errorf("session expired")
+var ErrInvalidEmail error = fmt.Errorf("invalid email")
+var ErrMeetingNotFound error = fmt.Errorf("meeting not found")
+var ErrNotWatching error = fmt.Errorf("not watching course")
+var ErrWrongPassword error = fmt.Errorf("wrong password")
+var ErrEmailTaken error = fmt.Errorf("email already in use")
+var ErrNoScrapeRun error = fmt.Errorf("no scrape run")
+var ErrCourseFull error = fmt.Errorf("course full")
+
+// Trims the email and lowercases its domain, leaving the local part as is.
+// An empty email is allowed since some instructors don't have one
+func normalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	// ParseAddress also accepts "Name <address>", which isn't an email
+	address, err := mail.ParseAddress(s)
+	if err != nil || address.Address != s {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, s)
+	}
+
+	at := strings.LastIndex(s, "@")
+
+	return s[:at] + strings.ToLower(s[at:]), nil
+}
+
+// The normalized email of one of course's instructors. The feed sometimes
+// has malformed emails, and rather than losing the course over one the
+// instructor is stored without an email and a warning logged
+func instructorEmail(instructor courseload.Instructor, term_crn string) string {
+	email, err := normalizeEmail(instructor.Email)
+	if err != nil {
+		slog.Warn("invalid instructor email", "term_crn", term_crn, "instructor", instructor.LastName+", "+instructor.FirstName, "error", err)
+		return ""
+	}
+
+	return email
+}
+
+// A nullable timestamp column as a pointer, nil when NULL
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      error
+	}{
+		{"jane.doe@unh.edu", "jane.doe@unh.edu", nil},
+		{"  Jane.Doe@UNH.EDU ", "Jane.Doe@unh.edu", nil},
+		{"", "", nil},
+		{"   ", "", nil},
+		{"jane.doe", "", ErrInvalidEmail},
+		{"Jane Doe <jane.doe@unh.edu>", "", ErrInvalidEmail},
+		{"jane doe@unh.edu", "", ErrInvalidEmail},
+	}
+
+	for _, test := range tests {
+		got, err := normalizeEmail(test.in)
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Errorf("normalizeEmail(%q) = %q, %v, want %q, %v", test.in, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestInvalidInstructorEmailStoredEmpty(t *testing.T) {
+	var logged bytes.Buffer
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	s := newTestStore(t)
+
+	single := testCourse("20241000001", "COMP", "400", "Data Structures")
+	single.Data.Instructors[0].Email = "not an email"
+
+	if err := s.InsertCourse(single); err != nil {
+		t.Fatalf("InsertCourse: %v", err)
+	}
+
+	bulk := testCourse("20241000002", "COMP", "405", "Software Engineering")
+	bulk.Data.Instructors = append(bulk.Data.Instructors, courseload.Instructor{LastName: "Roe", FirstName: "Rick", Email: "rick@"})
+
+	if errs, err := s.InsertCourses([]courseload.Course{bulk}); err != nil || errs[0] != nil {
+		t.Fatalf("InsertCourses: %v, %v", errs, err)
+	}
+
+	for crn, want := range map[string][]string{
+		"20241000001": {""},
+		"20241000002": {"jane.doe@unh.edu", ""},
+	} {
+		course, err := s.GetCourse(crn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		emails := make([]string, len(course.Data.Instructors))
+		for i, instructor := range course.Data.Instructors {
+			emails[i] = instructor.Email
+		}
+
+		if !slices.Equal(emails, want) {
+			t.Errorf("course %s instructor emails = %q, want %q", crn, emails, want)
+		}
+	}
+
+	for _, crn := range []string{"20241000001", "20241000002"} {
+		if !strings.Contains(logged.String(), "level=WARN msg=\"invalid instructor email\" term_crn="+crn) {
+			t.Errorf("no warning logged for course %s:\n%s", crn, logged.String())
+		}
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	useTestDatabase(t)
+
+	const crn, email = "20241099999", "nobody@unh.edu"
+
+	for name, err := range map[string]error{
+		"GetCourse":        func() error { _, err := GetCourse(crn); return err }(),
+		"GetCourseSummary": func() error { _, err := GetCourseSummary(crn); return err }(),
+		"UpdateCourse":     UpdateCourse(testCourse(crn, "COMP", "400", "Missing")),
+	} {
+		if !errors.Is(err, ErrCourseNotFound) || !strings.Contains(err.Error(), crn) {
+			t.Errorf("%s for a missing course = %v, want ErrCourseNotFound naming %s", name, err, crn)
+		}
+	}
+
+	for name, err := range map[string]error{
+		"GetUser":         func() error { _, err := GetUser(email); return err }(),
+		"VerifyUser":      func() error { _, err := VerifyUser(email, "password"); return err }(),
+		"GetUserClasses":  func() error { _, err := GetUserClasses(email); return err }(),
+		"SetUserClasses":  SetUserClasses(email, nil),
+		"RemoveUserClass": RemoveUserClass(email, crn),
+	} {
+		if !errors.Is(err, ErrUserNotFound) || !strings.Contains(err.Error(), email) {
+			t.Errorf("%s for a missing user = %v, want ErrUserNotFound naming %s", name, err, email)
+		}
+	}
+
+	if errors.Is(ErrCourseNotFound, ErrUserNotFound) {
+		t.Error("ErrCourseNotFound matches ErrUserNotFound")
+	}
+}
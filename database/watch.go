@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"hacknhbackend.eparker.dev/util"
+)
+
+// A watched course that went from no seats available to some
+type WatchHit struct {
+	Email          string
+	CRN            string
+	SeatsAvailable int
+}
+
+// Delivers a WatchHit to its user, by email, webhook or whatever else
+type Notifier interface {
+	Notify(hit WatchHit) error
+}
+
+// Watches a course for a seat opening up. Watching a course twice is the
+// same as watching it once
+func AddWatch(email, crn string) error {
+	transaction, err := QueuedBegin()
+	if err != nil {
+		return err
+	}
+
+	var exists int
+
+	err = transaction.QueryRow("SELECT 1 FROM users WHERE email = ?;", email).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		transaction.Rollback()
+		return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	} else if err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	var term_crn string
+	var seats_available int
+
+	err = transaction.QueryRow("SELECT term_crn, seats_available FROM courses WHERE term_crn = ? COLLATE NOCASE AND archived_at IS NULL;", crn).Scan(&term_crn, &seats_available)
+	if errors.Is(err, sql.ErrNoRows) {
+		transaction.Rollback()
+		return fmt.Errorf("%w: %s", ErrCourseNotFound, crn)
+	} else if err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	_, err = transaction.Exec("INSERT INTO watches (email, term_crn, last_seats_available, created_at) VALUES (?, ?, ?, ?) ON CONFLICT (email, term_crn) DO NOTHING;", email, term_crn, seats_available, time.Now().UTC())
+	if err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+func RemoveWatch(email, crn string) error {
+	result, err := QueuedExecResult("DELETE FROM watches WHERE email = ? AND term_crn = ? COLLATE NOCASE;", email, crn)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotWatching, crn)
+	}
+
+	return nil
+}
+
+// The watches whose course had no seats available at the last check and
+// has some now. Run after seat counts are refreshed. Every watch's seat
+// count is then brought up to date, so an opening is only reported once.
+// Courses whose seats aren't known, which includes every scraped course,
+// never open
+func CheckWatches() ([]WatchHit, error) {
+	transaction, err := QueuedBegin()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := transaction.Query(`SELECT w.email, w.term_crn, c.seats_available FROM watches w
+JOIN courses c ON c.term_crn = w.term_crn
+WHERE w.last_seats_available = 0 AND c.seats_total > 0 AND c.seats_available > 0 AND c.archived_at IS NULL
+ORDER BY w.term_crn, w.id;`)
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+
+	hits := make([]WatchHit, 0)
+
+	for rows.Next() {
+		var hit WatchHit
+		if err = rows.Scan(&hit.Email, &hit.CRN, &hit.SeatsAvailable); err != nil {
+			rows.Close()
+			transaction.Rollback()
+			return nil, err
+		}
+
+		hits = append(hits, hit)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+
+	_, err = transaction.Exec("UPDATE watches SET last_seats_available = (SELECT seats_available FROM courses WHERE courses.term_crn = watches.term_crn) WHERE term_crn IN (SELECT term_crn FROM courses);")
+	if err != nil {
+		transaction.Rollback()
+		return nil, err
+	}
+
+	return hits, transaction.Commit()
+}
+
+// Runs CheckWatches and passes every hit to notifier. A failed
+// notification is logged without stopping the rest, since the opening
+// won't be reported again
+func NotifyWatches(notifier Notifier) error {
+	hits, err := CheckWatches()
+	if err != nil {
+		return err
+	}
+
+	for _, hit := range hits {
+		if err := notifier.Notify(hit); err != nil {
+			util.Log.Error(fmt.Sprintf("Error notifying %s about %s: %v", hit.Email, hit.CRN, err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"hacknhbackend.eparker.dev/courseload"
+)
+
+// Records every hit, failing for the emails in fail
+type fakeNotifier struct {
+	hits []WatchHit
+	fail map[string]bool
+}
+
+func (n *fakeNotifier) Notify(hit WatchHit) error {
+	n.hits = append(n.hits, hit)
+
+	if n.fail[hit.Email] {
+		return fmt.Errorf("mailbox full")
+	}
+
+	return nil
+}
+
+func TestNotifyWatches(t *testing.T) {
+	useTestDatabase(t)
+
+	full := testCourse("20241000001", "COMP", "400", "Data Structures")
+	full.Data.SeatsTotal = 30
+
+	other := testCourse("20241000002", "COMP", "405", "Software Engineering")
+	other.Data.SeatsTotal = 30
+
+	for _, course := range []courseload.Course{full, other, testCourse("20241000003", "MATH", "425", "Calculus")} {
+		if err := InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Watches are created in a fixed order, since hits for one course come
+	// back in the order their watches were added
+	watches := []struct {
+		email string
+		crns  []string
+	}{
+		{"a@unh.edu", []string{"20241000001", "20241000003"}},
+		{"b@unh.edu", []string{"20241000001"}},
+		{"c@unh.edu", []string{"20241000002"}},
+	}
+
+	for _, watch := range watches {
+		if _, status := CreateUser(watch.email, "A", "B", "password"); status != CREATE_USER_SUCCESS {
+			t.Fatalf("creating %s: %d", watch.email, status)
+		}
+
+		for _, crn := range watch.crns {
+			if err := AddWatch(watch.email, crn); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	setSeats := func(course courseload.Course, available int) {
+		t.Helper()
+
+		course.Data.SeatsAvailable = available
+		if err := UpdateCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notify := func(name string, want ...string) {
+		t.Helper()
+
+		notifier := &fakeNotifier{fail: map[string]bool{"a@unh.edu": true}}
+		if err := NotifyWatches(notifier); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got := make([]string, 0, len(notifier.hits))
+		for _, hit := range notifier.hits {
+			got = append(got, fmt.Sprintf("%s %s %d", hit.Email, hit.CRN, hit.SeatsAvailable))
+		}
+
+		if want == nil {
+			want = []string{}
+		}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: notified %v, want %v", name, got, want)
+		}
+	}
+
+	notify("nothing open")
+
+	setSeats(full, 3)
+	notify("seat opened", "a@unh.edu 20241000001 3", "b@unh.edu 20241000001 3")
+	notify("already reported")
+
+	setSeats(full, 2)
+	notify("still open")
+
+	setSeats(full, 0)
+	notify("full again")
+
+	setSeats(full, 1)
+	notify("opened again", "a@unh.edu 20241000001 1", "b@unh.edu 20241000001 1")
+}
@@ -80,6 +80,83 @@ func withCors(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Pragma", "no-cache")
 }
 
+// Splits a query value into the values QueryCourse expects for key
+func queryValues(key, value string) []string {
+	switch key {
+	case "subject-number":
+		return strings.Split(value, "-")
+	case "subject_code":
+		return strings.Split(value, ",")
+	default:
+		return []string{value}
+	}
+}
+
+// Runs QueryCourse with the key and value query parameters and writes the
+// matching courses as JSON. For subject_code, match may be exact (the
+// default), prefix or contains
+func CourseSearchHandler(w http.ResponseWriter, r *http.Request) {
+	withCors(w, r)
+
+	key, value := r.URL.Query().Get("key"), r.URL.Query().Get("value")
+
+	if _, ok := database.QueryableKeys[key]; !ok || value == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	match, err := database.ParseSubjectMatch(r.URL.Query().Get("match"))
+	if err != nil || (match != database.SubjectExact && key != "subject_code") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var courses []courseload.Course
+
+	if key == "subject_code" {
+		courses, err = database.QueryCourseSubjectContext(r.Context(), match, queryValues(key, value)...)
+	} else {
+		courses, err = database.QueryCourseContext(r.Context(), key, queryValues(key, value)...)
+	}
+
+	if err != nil {
+		util.Log.Error(fmt.Sprintf("Error searching courses: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if jsonCourses, err := json.Marshal(courses); err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonCourses)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// Course data older than this is reported by /healthz, though the server
+// stays healthy since it can still serve what it has
+const staleCoursesAfter = 48 * time.Hour
+
+// Readiness probe. Fails when the database is unreachable, and carries a
+// Warning header when no scrape run has succeeded in staleCoursesAfter
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := database.HealthCheck(r.Context()); err != nil {
+		util.Log.Error(err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if age, err := database.StalestCourseAge(); err != nil {
+		util.Log.Error(fmt.Sprintf("Error checking course age: %v", err))
+		w.Header().Set("Warning", `199 - "course data age unknown"`)
+	} else if age > staleCoursesAfter {
+		util.Log.Error(fmt.Sprintf("Course data is stale, last scraped %v ago", age.Round(time.Minute)))
+		w.Header().Set("Warning", fmt.Sprintf(`199 - "course data last scraped %v ago"`, age.Round(time.Minute)))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func main() {
 	util.LoadEnvFile()
 	database.Init()
@@ -94,10 +171,13 @@ func main() {
 		http.ServeFile(w, r, "index.html")
 	})
 
+	// Readiness probe
+	http.HandleFunc("/healthz", HealthHandler)
+
 	// All users (SAFE)
 	http.HandleFunc("/user/all", func(w http.ResponseWriter, r *http.Request) {
 		withCors(w, r)
-		users, err := database.AllUsers()
+		users, err := database.AllUsersContext(r.Context())
 
 		if err != nil {
 			return
@@ -140,7 +220,7 @@ func main() {
 			return
 		}
 
-		user, err := database.GetUser(strings.ToLower(obj.Email))
+		user, err := database.GetUserContext(r.Context(), strings.ToLower(obj.Email))
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -182,7 +262,7 @@ func main() {
 			return
 		}
 
-		user, statusCode := database.CreateUser(strings.ToLower(obj.Email), obj.First, obj.Last, obj.Password)
+		user, statusCode := database.CreateUserContext(r.Context(), strings.ToLower(obj.Email), obj.First, obj.Last, obj.Password)
 
 		if statusCode != database.CREATE_USER_SUCCESS {
 			switch statusCode {
@@ -247,14 +327,7 @@ func main() {
 			return
 		}
 
-		user, err := database.GetUser(strings.ToLower(obj.Email))
-
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		if user.PasswordHash != database.HashPassword(obj.Password) {
+		if ok, err := database.VerifyUserContext(r.Context(), strings.ToLower(obj.Email), obj.Password); err != nil || !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -286,7 +359,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		user, err := database.GetUser(email.Value)
+		user, err := database.GetUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -332,7 +405,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		err := database.DeleteUser(email.Value)
+		err := database.DeleteUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -390,7 +463,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		user, err := database.GetUser(email.Value)
+		user, err := database.GetUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -437,7 +510,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		user, err := database.GetUser(email.Value)
+		user, err := database.GetUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -484,7 +557,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		user, err := database.GetUser(email.Value)
+		user, err := database.GetUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -508,7 +581,7 @@ func main() {
 			return
 		}
 
-		courses, err := database.GetCourseCRNs()
+		courses, err := database.GetCourseCRNsContext(r.Context())
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -550,7 +623,7 @@ func main() {
 			return
 		}
 
-		course, err := database.GetCourse(obj.CRN)
+		course, err := database.GetCourseContext(r.Context(), obj.CRN)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -562,6 +635,8 @@ func main() {
 	})
 
 	// Get courses by subject code
+	http.HandleFunc("/course/search", CourseSearchHandler)
+
 	http.HandleFunc("/course/query/list", func(w http.ResponseWriter, r *http.Request) {
 		withCors(w, r)
 
@@ -600,13 +675,7 @@ func main() {
 			return
 		}
 
-		var courses []courseload.Course
-
-		if obj.QueryKey == "subject-number" {
-			courses, err = database.QueryCourse(obj.QueryKey, strings.Split(obj.QueryValue, "-")...)
-		} else {
-			courses, err = database.QueryCourse(obj.QueryKey, obj.QueryValue)
-		}
+		courses, err := database.QueryCourseContext(r.Context(), obj.QueryKey, queryValues(obj.QueryKey, obj.QueryValue)...)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -688,7 +757,7 @@ func main() {
 		}
 
 		email, _ := r.Cookie("email")
-		user, err := database.GetUser(email.Value)
+		user, err := database.GetUserContext(r.Context(), email.Value)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -698,7 +767,7 @@ func main() {
 		var courses [][]database.User
 
 		for _, crn := range user.Courses {
-			if users, err := database.UsersInCourse(crn); err == nil {
+			if users, err := database.UsersInCourseContext(r.Context(), crn); err == nil {
 				courses = append(courses, users)
 			}
 		}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hacknhbackend.eparker.dev/courseload"
+	"hacknhbackend.eparker.dev/database"
+)
+
+func TestCourseSearchHandler(t *testing.T) {
+	database.InitAt(t.TempDir() + "/db.sqlite")
+	t.Cleanup(func() { database.CloseDatabase() })
+
+	for _, course := range []courseload.Course{
+		{CRN: "20241012345", Data: courseload.CourseData{Title: "Software Engineering", Subject: "COMP", Number: "405"}},
+		{CRN: "20241054321", Data: courseload.CourseData{Title: "Calculus I", Subject: "MATH", Number: "425"}},
+	} {
+		if err := database.InsertCourse(course); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		crns   []string
+	}{
+		{"title", "key=title&value=Software", http.StatusOK, []string{"20241012345"}},
+		{"several subjects", "key=subject_code&value=COMP,MATH", http.StatusOK, []string{"20241012345", "20241054321"}},
+		{"subject prefix", "key=subject_code&value=MA&match=prefix", http.StatusOK, []string{"20241054321"}},
+		{"no matches", "key=title&value=Astronomy", http.StatusOK, []string{}},
+		{"unknown key", "key=password&value=x", http.StatusBadRequest, nil},
+		{"missing value", "key=title", http.StatusBadRequest, nil},
+		{"match on another key", "key=title&value=Software&match=prefix", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		CourseSearchHandler(recorder, httptest.NewRequest(http.MethodGet, "/course/search?"+test.query, nil))
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.status)
+			continue
+		}
+
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s: Content-Type = %q", test.name, contentType)
+		}
+
+		var courses []courseload.Course
+		if err := json.Unmarshal(recorder.Body.Bytes(), &courses); err != nil {
+			t.Errorf("%s: decoding body: %v", test.name, err)
+			continue
+		}
+
+		if len(courses) != len(test.crns) {
+			t.Errorf("%s: got %d courses, want %v", test.name, len(courses), test.crns)
+			continue
+		}
+
+		for i, course := range courses {
+			if course.CRN != test.crns[i] {
+				t.Errorf("%s: course %d = %s, want %s", test.name, i, course.CRN, test.crns[i])
+			}
+		}
+	}
+
+	database.CloseDatabase()
+
+	recorder := httptest.NewRecorder()
+	CourseSearchHandler(recorder, httptest.NewRequest(http.MethodGet, "/course/search?key=title&value=Software", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("closed database: status = %d, want %d", recorder.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	database.InitAt(t.TempDir() + "/db.sqlite")
+	t.Cleanup(func() { database.CloseDatabase() })
+
+	tests := []struct {
+		name    string
+		setup   func() error
+		status  int
+		warning string
+	}{
+		{"no scrape runs", func() error { return nil }, http.StatusOK, "course data age unknown"},
+		{"stale", func() error {
+			return database.QueuedExec("INSERT INTO scrape_runs (started_at, finished_at, success) VALUES (?, ?, 1);", time.Now().UTC().Add(-3*staleCoursesAfter), time.Now().UTC().Add(-3*staleCoursesAfter))
+		}, http.StatusOK, "course data last scraped"},
+		{"course changed since", func() error {
+			return database.InsertCourse(courseload.Course{CRN: "20241012345", Data: courseload.CourseData{Title: "Software Engineering", Subject: "COMP", Number: "405"}})
+		}, http.StatusOK, "course data last scraped"},
+		{"fresh", func() error {
+			run, err := database.StartScrapeRun()
+			if err != nil {
+				return err
+			}
+
+			return database.FinishScrapeRun(run, database.ScrapeRunStats{})
+		}, http.StatusOK, ""},
+		{"closed", database.CloseDatabase, http.StatusServiceUnavailable, ""},
+	}
+
+	for _, test := range tests {
+		if err := test.setup(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		recorder := httptest.NewRecorder()
+		HealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.status)
+		}
+
+		if warning := recorder.Header().Get("Warning"); !strings.Contains(warning, test.warning) || (test.warning == "") != (warning == "") {
+			t.Errorf("%s: Warning = %q, want it to mention %q", test.name, warning, test.warning)
+		}
+	}
+}
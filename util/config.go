@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/lpernett/godotenv"
 )
@@ -27,6 +28,11 @@ var Config struct {
 	Mapbox struct {
 		AccessToken string
 	}
+
+	// Optional, bounds recurring events in exported schedules
+	Term struct {
+		Start, End time.Time
+	}
 }
 
 func LoadEnvFile() {
@@ -65,6 +71,8 @@ func LoadEnvFile() {
 				file.WriteString("GENERAL_UPDATE_COURSES=\n")
 				file.WriteString("MAPBOX_ACCESS_TOKEN=\n")
 				file.WriteString("TLS_DIRECTORY=\n")
+				file.WriteString("TERM_START=\n")
+				file.WriteString("TERM_END=\n")
 
 				file.Close()
 
@@ -160,5 +168,23 @@ func LoadEnvFile() {
 		Config.Server.TLS = tmp.(string)
 	}
 
+	if tmp = os.Getenv("TERM_START"); tmp != "" {
+		if t, err := time.Parse(time.DateOnly, tmp.(string)); err != nil {
+			Log.Error("TERM_START not a date (YYYY-MM-DD)")
+			os.Exit(1)
+		} else {
+			Config.Term.Start = t
+		}
+	}
+
+	if tmp = os.Getenv("TERM_END"); tmp != "" {
+		if t, err := time.Parse(time.DateOnly, tmp.(string)); err != nil {
+			Log.Error("TERM_END not a date (YYYY-MM-DD)")
+			os.Exit(1)
+		} else {
+			Config.Term.End = t
+		}
+	}
+
 	Log.Status("Loaded environment variables")
 }